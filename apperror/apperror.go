@@ -0,0 +1,139 @@
+package apperror
+
+import (
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Code is the stable error code exposed to clients in extensions.code.
+type Code string
+
+const (
+	CodeUnauthenticated    Code = "UNAUTHENTICATED"
+	CodeForbidden          Code = "FORBIDDEN"
+	CodeBadUserInput       Code = "BAD_USER_INPUT"
+	CodeNotFound           Code = "NOT_FOUND"
+	CodeAlreadyExists      Code = "ALREADY_EXISTS"
+	CodeFailedPrecondition Code = "FAILED_PRECONDITION"
+	CodeRateLimited        Code = "RATE_LIMITED"
+	CodeTimeout            Code = "TIMEOUT"
+	CodeServiceUnavailable Code = "SERVICE_UNAVAILABLE"
+	CodeValidationFailed   Code = "GRAPHQL_VALIDATION_FAILED"
+	CodeInternal           Code = "INTERNAL_SERVER_ERROR"
+)
+
+var messages = map[Code]string{
+	CodeUnauthenticated:    "you are not logged in",
+	CodeForbidden:          "you are not allowed to perform this action",
+	CodeBadUserInput:       "invalid input",
+	CodeNotFound:           "resource not found",
+	CodeAlreadyExists:      "resource already exists",
+	CodeFailedPrecondition: "the request cannot be processed in the current state",
+	CodeRateLimited:        "too many requests, try again later",
+	CodeTimeout:            "the request timed out",
+	CodeServiceUnavailable: "service temporarily unavailable",
+	CodeInternal:           "internal server error",
+}
+
+var grpcCodes = map[codes.Code]Code{
+	codes.InvalidArgument:    CodeBadUserInput,
+	codes.OutOfRange:         CodeBadUserInput,
+	codes.NotFound:           CodeNotFound,
+	codes.AlreadyExists:      CodeAlreadyExists,
+	codes.FailedPrecondition: CodeFailedPrecondition,
+	codes.Aborted:            CodeFailedPrecondition,
+	codes.PermissionDenied:   CodeForbidden,
+	codes.Unauthenticated:    CodeUnauthenticated,
+	codes.ResourceExhausted:  CodeRateLimited,
+	codes.DeadlineExceeded:   CodeTimeout,
+	codes.Canceled:           CodeTimeout,
+	codes.Unavailable:        CodeServiceUnavailable,
+}
+
+// Error is an error that is safe to show to clients. Internal holds the
+// underlying cause, which is only ever logged. Details are added to the
+// extensions as they are.
+type Error struct {
+	Code     Code
+	Message  string
+	Fields   []FieldError
+	Details  map[string]interface{}
+	Internal error
+}
+
+// FieldError describes why a single input field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Internal
+}
+
+func (e *Error) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{
+		"code": string(e.Code),
+	}
+	if len(e.Fields) > 0 {
+		extensions["fields"] = e.Fields
+	}
+	for key, value := range e.Details {
+		if _, ok := extensions[key]; !ok {
+			extensions[key] = value
+		}
+	}
+	return extensions
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func Wrap(code Code, err error) *Error {
+	return &Error{Code: code, Message: Message(code), Internal: err}
+}
+
+// Message returns the default user facing message for a code.
+func Message(code Code) string {
+	if msg, ok := messages[code]; ok {
+		return msg
+	}
+	return messages[CodeInternal]
+}
+
+// FromGRPC translates a gRPC status error into an Error. The status message
+// comes from the backend, so it is only kept for user input errors.
+func FromGRPC(err error) *Error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return Wrap(CodeInternal, err)
+	}
+	code, ok := grpcCodes[st.Code()]
+	if !ok {
+		return Wrap(CodeInternal, err)
+	}
+	appErr := Wrap(code, err)
+	if code == CodeBadUserInput && st.Message() != "" {
+		appErr.Message = st.Message()
+	}
+	return appErr
+}
+
+// Convert maps any error returned by a resolver into an Error.
+func Convert(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if _, ok := status.FromError(err); ok {
+		return FromGRPC(err)
+	}
+	return Wrap(CodeInternal, err)
+}
@@ -64,6 +64,7 @@ func ValidateToken(tokenString string, secret []byte) (map[string]interface{}, e
 		"userId":    claims.UserId,
 		"isAdmin":   claims.IsAdmin,
 		"isSuAdmin": claims.IsSuAdmin,
+		"expiresAt": claims.ExpiresAt,
 	}
 
 	if claims.ExpiresAt < time.Now().Unix() {
@@ -0,0 +1,147 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+type operation struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// ContextFn builds the context of one operation of a batch. w only collects
+// headers, they are copied to the response once the batch is done.
+type ContextFn func(w http.ResponseWriter, r *http.Request) context.Context
+
+// Handler executes POST bodies holding a JSON array of operations
+// concurrently and answers with the array of results in the same order.
+// Every other request is passed on to Next.
+type Handler struct {
+	Schema        *graphql.Schema
+	FormatErrorFn func(err error) gqlerrors.FormattedError
+	ContextFn     ContextFn
+	MaxSize       int
+	Next          http.Handler
+}
+
+func NewHandler(schema *graphql.Schema, formatErrorFn func(err error) gqlerrors.FormattedError, contextFn ContextFn, maxSize int, next http.Handler) *Handler {
+	return &Handler{
+		Schema:        schema,
+		FormatErrorFn: formatErrorFn,
+		ContextFn:     contextFn,
+		MaxSize:       maxSize,
+		Next:          next,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Body == nil {
+		h.Next.ServeHTTP(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil || !isArray(body) {
+		h.Next.ServeHTTP(w, r)
+		return
+	}
+
+	var operations []operation
+	if err := json.Unmarshal(body, &operations); err != nil {
+		writeError(w, http.StatusBadRequest, "batch must be an array of operations")
+		return
+	}
+	if len(operations) == 0 {
+		writeError(w, http.StatusBadRequest, "batch is empty")
+		return
+	}
+	if h.MaxSize > 0 && len(operations) > h.MaxSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "batch is larger than the limit")
+		return
+	}
+
+	results := make([]*graphql.Result, len(operations))
+	headers := make([]http.Header, len(operations))
+	var wg sync.WaitGroup
+	for i, op := range operations {
+		wg.Add(1)
+		go func(i int, op operation) {
+			defer wg.Done()
+			recorder := &headerRecorder{header: http.Header{}}
+			results[i] = h.execute(h.ContextFn(recorder, r), op)
+			headers[i] = recorder.header
+		}(i, op)
+	}
+	wg.Wait()
+
+	for _, header := range headers {
+		for key, values := range header {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(results)
+}
+
+func (h *Handler) execute(ctx context.Context, op operation) *graphql.Result {
+	result := graphql.Do(graphql.Params{
+		Schema:         *h.Schema,
+		RequestString:  op.Query,
+		VariableValues: op.Variables,
+		OperationName:  op.OperationName,
+		Context:        ctx,
+	})
+	if h.FormatErrorFn != nil && len(result.Errors) > 0 {
+		formatted := make([]gqlerrors.FormattedError, len(result.Errors))
+		for i, err := range result.Errors {
+			formatted[i] = h.FormatErrorFn(err.OriginalError())
+		}
+		result.Errors = formatted
+	}
+	return result
+}
+
+func isArray(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&graphql.Result{
+		Errors: []gqlerrors.FormattedError{{
+			Message:    message,
+			Extensions: map[string]interface{}{"code": string(apperror.CodeBadUserInput)},
+		}},
+	})
+}
+
+// headerRecorder is the response writer of a single operation, resolvers
+// only set headers on it.
+type headerRecorder struct {
+	header http.Header
+}
+
+func (h *headerRecorder) Header() http.Header {
+	return h.header
+}
+
+func (h *headerRecorder) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (h *headerRecorder) WriteHeader(int) {}
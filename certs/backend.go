@@ -0,0 +1,67 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// BackendTLS is how the gateway secures the connection to one backend
+// service. CAFile replaces the system roots when set, CertFile and KeyFile
+// turn on mutual TLS.
+type BackendTLS struct {
+	Enabled    bool
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+}
+
+// DialOption returns the transport credentials for a backend, plaintext when
+// TLS is not enabled. The client certificate is watched like the server one
+// for as long as ctx lives.
+func DialOption(ctx context.Context, cfg BackendTLS, reloadInterval time.Duration) (grpc.DialOption, error) {
+	if !cfg.Enabled {
+		return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("client certificate and key have to be set together")
+	}
+	if cfg.CertFile != "" {
+		reloader, err := NewReloader(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if reloadInterval > 0 {
+			go reloader.Watch(ctx, reloadInterval)
+		}
+		tlsConfig.GetClientCertificate = reloader.GetClientCertificate
+	}
+
+	return grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)), nil
+}
@@ -2,39 +2,47 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/Nishad4140/api_gateway/batch"
+	"github.com/Nishad4140/api_gateway/cache"
+	"github.com/Nishad4140/api_gateway/certs"
+	"github.com/Nishad4140/api_gateway/config"
+	"github.com/Nishad4140/api_gateway/discovery"
+	"github.com/Nishad4140/api_gateway/events"
 	graph "github.com/Nishad4140/api_gateway/graphql"
+	"github.com/Nishad4140/api_gateway/incremental"
 	"github.com/Nishad4140/api_gateway/middleware"
+	"github.com/Nishad4140/api_gateway/settings"
+	"github.com/Nishad4140/api_gateway/subscription"
+	"github.com/Nishad4140/api_gateway/webhook"
 	"github.com/Nishad4140/proto_files/pb"
 	"github.com/graphql-go/handler"
-	"github.com/joho/godotenv"
 	"google.golang.org/grpc"
 )
 
 func main() {
 
-	productConn, err := grpc.Dial("localhost:3000", grpc.WithInsecure())
-	if err != nil {
-		log.Println(err.Error())
+	configFile := os.Getenv("CONFIG_FILE")
+	if configFile == "" {
+		configFile = "../.env"
 	}
-
-	userConn, err := grpc.Dial("localhost:3002", grpc.WithInsecure())
+	cfg, err := config.Load(configFile)
 	if err != nil {
-		log.Println(err.Error())
+		log.Fatal(err.Error())
 	}
-
-	cartConn, err := grpc.Dial("localhost:3003", grpc.WithInsecure())
-	if err != nil {
-		log.Println(err.Error())
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err.Error())
 	}
 
-	orderConn, err := grpc.Dial("localhost:3004", grpc.WithInsecure())
-	if err != nil {
-		log.Println(err.Error())
-	}
+	productConn := dialBackend(cfg, "product", cfg.ProductBackend)
+	userConn := dialBackend(cfg, "user", cfg.UserBackend)
+	cartConn := dialBackend(cfg, "cart", cfg.CartBackend)
+	orderConn := dialBackend(cfg, "order", cfg.OrderBackend)
 
 	defer func() {
 		productConn.Close()
@@ -48,33 +56,274 @@ func main() {
 	cartRes := pb.NewCartServiceClient(cartConn)
 	orderRes := pb.NewOrderServiceClient(orderConn)
 
-	if err := godotenv.Load("../.env"); err != nil {
-		log.Fatal(err.Error())
+	graph.Initialize(productRes, userRes, cartRes, orderRes)
+	graph.RetrieveSecret(cfg.Secret)
+	middleware.InitMiddlewareSecret(cfg.Secret)
+	graph.ConfigureEnvironment(cfg.IsDevelopment())
+	bus := events.NewBus()
+	graph.InitEventBus(bus)
+	graph.ConfigureOrderEnums(cfg.OrderStatusIDs, cfg.PaymentTypeIDs)
+
+	sender := webhook.NewSender(cfg.WebhookMaxAttempts, cfg.WebhookRetryDelay)
+	if cfg.LowStockWebhookURL != "" {
+		go webhook.Forward(context.Background(), bus, events.TopicLowStock, cfg.LowStockWebhookURL, cfg.LowStockWebhookSecret, sender)
 	}
-	secretString := os.Getenv("SECRET")
 
-	graph.Initialize(productRes, userRes, cartRes, orderRes)
-	graph.RetrieveSecret(secretString)
-	middleware.InitMiddlewareSecret(secretString)
+	dispatcher, err := webhook.NewDispatcher(cfg.WebhookStorePath, sender)
+	if err != nil {
+		log.Println("webhooks disabled:", err.Error())
+	} else {
+		graph.InitWebhooks(dispatcher)
+		go dispatcher.Run(context.Background())
+	}
+
+	if cfg.CacheEnabled {
+		graph.InitCache(cache.NewLRU(cfg.CacheSize))
+	}
+
+	if err := graph.CheckSchema(&graph.Schema); err != nil {
+		log.Fatal(err.Error())
+	}
 
 	h := handler.New(&handler.Config{
-		Schema: &graph.Schema,
-		Pretty: true,
+		Schema:        &graph.Schema,
+		Pretty:        cfg.IsDevelopment(),
+		GraphiQL:      cfg.IsDevelopment(),
+		FormatErrorFn: graph.FormatError,
 	})
 
-	http.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+	// super admins get GraphiQL even outside development
+	adminHandler := handler.New(&handler.Config{
+		Schema:        &graph.Schema,
+		Pretty:        true,
+		GraphiQL:      true,
+		FormatErrorFn: graph.FormatError,
+	})
+
+	// subscriptions are served over graphql-transport-ws on the same route
+	wsHandler := subscription.NewHandler(&graph.Schema, graph.FormatError, func(r *http.Request, payload map[string]interface{}) context.Context {
+		// browsers send the session cookie with the upgrade request, other
+		// clients can pass the token in the connection_init payload instead
+		if token, ok := payload["token"].(string); ok && token != "" {
+			r = r.Clone(r.Context())
+			r.AddCookie(&http.Cookie{Name: middleware.SessionCookieName(r.Context()), Value: token})
+		}
+		ctx := context.WithValue(r.Context(), "request", r)
+		return context.WithValue(ctx, "principal", middleware.Authenticate(r))
+	})
+	wsHandler.CheckOrigin = func(r *http.Request) bool {
+		if check := settings.From(r.Context()).WebSocketOrigin; check != nil {
+			return check(r)
+		}
+		return subscription.SameOrigin(r)
+	}
+	wsHandler.ExpiresFn = func(ctx context.Context) time.Time {
+		return middleware.PrincipalFrom(ctx).ExpiresAt
+	}
+
+	queryHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if !cfg.IsDevelopment() && middleware.PrincipalFrom(ctx).IsSuAdmin {
+			adminHandler.ContextHandler(ctx, w, r)
+			return
+		}
+		h.ContextHandler(ctx, w, r)
+	})
+
+	// queries using @defer or @stream get a multipart/mixed response when
+	// the client accepts one
+	incrementalHandler := incremental.NewHandler(&graph.Schema, graph.FormatError, queryHandler)
+
+	requestContext := func(w http.ResponseWriter, r *http.Request) context.Context {
 		// Add the http.ResponseWriter to the context.
 		ctx := context.WithValue(r.Context(), "httpResponseWriter", w)
 		ctx = context.WithValue(ctx, "request", r)
+		ctx = context.WithValue(ctx, "principal", middleware.Authenticate(r))
+		return context.WithValue(ctx, "loaders", graph.NewLoaders(ctx))
+	}
 
-		// Update the request's context.
-		r = r.WithContext(ctx)
+	// newRoute builds the /graphql handler chain for cfg, it is built again
+	// on every config reload
+	newRoute := func(cfg *config.Config) (http.Handler, error) {
+		// JSON arrays of operations run concurrently, each with its own context
+		batchHandler := batch.NewHandler(&graph.Schema, graph.FormatError, requestContext, cfg.BatchMaxSize, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Update the request's context.
+			r = r.WithContext(requestContext(w, r))
 
-		// Call the GraphQL handler.
-		h.ContextHandler(ctx, w, r)
+			// Call the GraphQL handler.
+			incrementalHandler.ServeHTTP(w, r)
+		}))
+
+		graphqlHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subscription.IsWebSocket(r) {
+				wsHandler.ServeHTTP(w, r)
+				return
+			}
+			batchHandler.ServeHTTP(w, r)
+		})
+
+		return middleware.CORS(middleware.CORSConfig{
+			AllowedOrigins:   cfg.CORSAllowedOrigins,
+			AllowCredentials: cfg.CORSAllowCredentials,
+			AllowedHeaders:   cfg.CORSAllowedHeaders,
+			MaxAge:           cfg.CORSMaxAge,
+		}, middleware.QueriesOnlyOverGET(middleware.CSRF(cfg.CSRFTrustedOrigins, middleware.CacheHeaders(cfg.CacheTTL, graphqlHandler))))
+	}
+
+	// apply publishes the runtime settings of cfg in one snapshot, the
+	// backends then move over to their new addresses
+	apply := func(cfg *config.Config) error {
+		route, err := newRoute(cfg)
+		if err != nil {
+			return err
+		}
+		s, err := newSettings(cfg, route)
+		if err != nil {
+			return err
+		}
+		settings.Publish(s)
+		updateBackendAddrs(cfg)
+		return nil
+	}
+	if err := apply(cfg); err != nil {
+		log.Fatal(err.Error())
+	}
+	go config.Watch(context.Background(), configFile, cfg, apply)
+
+	// the public listener only serves /graphql, the expvar stats are kept
+	// to the admin listener
+	mux := http.NewServeMux()
+	mux.Handle("/graphql", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := settings.Current()
+		s.Handler.ServeHTTP(w, r.WithContext(settings.NewContext(r.Context(), s)))
+	}))
+
+	if cfg.AdminAddr != "" {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("/debug/vars", expvar.Handler())
+		go func() {
+			log.Println("serving stats on", cfg.AdminAddr)
+			log.Println("admin listener stopped:", http.ListenAndServe(cfg.AdminAddr, adminMux))
+		}()
+	}
+
+	server := &http.Server{Addr: cfg.ListenAddr, Handler: mux}
+	if cfg.TLSCertFile == "" {
+		log.Println("listening on", cfg.ListenAddr, "of api gateway")
+		log.Fatal(server.ListenAndServe())
+	}
+
+	reloader, err := certs.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if cfg.TLSReloadInterval > 0 {
+		go reloader.Watch(context.Background(), cfg.TLSReloadInterval)
+	}
+	server.TLSConfig = certs.ServerConfig(reloader)
+
+	log.Println("listening with TLS on", cfg.ListenAddr, "of api gateway")
+	log.Fatal(server.ListenAndServeTLS("", ""))
+}
+
+// newSettings builds the snapshot of the settings that can change at
+// runtime, with route serving /graphql.
+func newSettings(cfg *config.Config, route http.Handler) (*settings.Settings, error) {
+	sameSite, err := middleware.ParseSameSite(cfg.SessionCookieSameSite)
+	if err != nil {
+		return nil, err
+	}
+	cookie, err := middleware.SessionCookie(settings.Cookie{
+		Name:       cfg.SessionCookieName,
+		Domain:     cfg.SessionCookieDomain,
+		Path:       "/",
+		Secure:     cfg.SessionCookieSecure,
+		SameSite:   sameSite,
+		HostPrefix: cfg.SessionCookieHostPrefix,
 	})
+	if err != nil {
+		return nil, err
+	}
+	return &settings.Settings{
+		SessionCookie: cookie,
+		StockCheck:    cfg.OrderStockCheck,
+		Streams: settings.Streams{
+			MaxItems: cfg.StreamMaxItems,
+			Timeout:  cfg.StreamTimeout,
+			Policy:   cfg.StreamPolicy,
+		},
+		LoaderConcurrency: cfg.LoaderConcurrency,
+		LowStock: settings.LowStock{
+			Threshold:  cfg.LowStockThreshold,
+			PerProduct: cfg.LowStockProductThresholds,
+		},
+		CacheTTL:        cfg.CacheTTL,
+		WebSocketOrigin: middleware.WebSocketOrigin(cfg.CORSAllowedOrigins, cfg.CSRFTrustedOrigins),
+		Handler:         route,
+	}, nil
+}
 
-	log.Println("listening on port :3001 of api gateway")
+// updateBackendAddrs hands the addresses of the static backends to their
+// connections.
+func updateBackendAddrs(cfg *config.Config) {
+	for name, backend := range map[string]config.Backend{
+		"product": cfg.ProductBackend,
+		"user":    cfg.UserBackend,
+		"cart":    cfg.CartBackend,
+		"order":   cfg.OrderBackend,
+	} {
+		if list, ok := staticAddrs[name]; ok && backend.StaticAddrs() {
+			list.Set(backend.Addrs)
+		}
+	}
+}
 
-	http.ListenAndServe(":3001", nil)
+// staticAddrs are the address lists of the backends with static discovery,
+// a config reload replaces them in place.
+var staticAddrs = map[string]*discovery.List{}
+
+func dialBackend(cfg *config.Config, name string, backend config.Backend) *grpc.ClientConn {
+	creds, err := certs.DialOption(context.Background(), certs.BackendTLS{
+		Enabled:    backend.TLS,
+		CAFile:     backend.TLSCAFile,
+		CertFile:   backend.TLSCertFile,
+		KeyFile:    backend.TLSKeyFile,
+		ServerName: backend.TLSServerName,
+	}, cfg.TLSReloadInterval)
+	if err != nil {
+		log.Fatalf("%s service: %s", name, err.Error())
+	}
+
+	var conn *grpc.ClientConn
+	if backend.Discovery == "static" && !backend.StaticAddrs() {
+		conn, err = discovery.DialTarget(backend.Addrs[0], backend.Balancer, creds)
+	} else {
+		opts := discovery.Options{Balancer: backend.Balancer}
+		if backend.OutlierEjection {
+			opts.Outlier = &discovery.OutlierConfig{
+				Interval:           cfg.OutlierInterval,
+				BaseEjectionTime:   cfg.OutlierBaseEjectionTime,
+				FailurePercent:     cfg.OutlierFailurePercent,
+				MinRequests:        cfg.OutlierMinRequests,
+				MaxEjectionPercent: cfg.OutlierMaxEjectionPercent,
+			}
+		}
+		conn, err = discovery.Dial(context.Background(), name, backendSource(cfg, name, backend), opts, creds)
+	}
+	if err != nil {
+		log.Fatalf("%s service: %s", name, err.Error())
+	}
+	return conn
+}
+
+func backendSource(cfg *config.Config, name string, backend config.Backend) discovery.Source {
+	switch backend.Discovery {
+	case "dns":
+		return discovery.DNSSRV{Name: backend.SRVName, Interval: cfg.DiscoveryInterval}
+	case "file":
+		return discovery.File{Path: backend.AddrsFile, Interval: cfg.DiscoveryInterval}
+	}
+	list := discovery.NewList(backend.Addrs)
+	staticAddrs[name] = list
+	return list
 }
@@ -0,0 +1,299 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+type Config struct {
+	Secret            string
+	Environment       string
+	LoaderConcurrency int
+
+	LowStockThreshold         int32
+	LowStockProductThresholds map[uint32]int32
+	LowStockWebhookURL        string
+	LowStockWebhookSecret     string
+	WebhookMaxAttempts        int
+	WebhookRetryDelay         time.Duration
+	WebhookStorePath          string
+
+	CacheEnabled bool
+	CacheTTL     time.Duration
+	CacheSize    int
+
+	OrderStatusIDs map[string]uint32
+	PaymentTypeIDs map[string]uint32
+
+	// OrderStockCheck is "enforce", "advisory" or "off"
+	OrderStockCheck string
+
+	StreamMaxItems int
+	StreamTimeout  time.Duration
+	// StreamPolicy is "fail" or "partial"
+	StreamPolicy string
+
+	BatchMaxSize int
+
+	CSRFTrustedOrigins []string
+
+	SessionCookieName       string
+	SessionCookieDomain     string
+	SessionCookieSecure     bool
+	SessionCookieSameSite   string
+	SessionCookieHostPrefix bool
+
+	CORSAllowedOrigins   []string
+	CORSAllowCredentials bool
+	CORSAllowedHeaders   []string
+	CORSMaxAge           time.Duration
+
+	ListenAddr string
+	// AdminAddr serves /debug/vars, keep it off public interfaces
+	AdminAddr string
+	// TLSCertFile and TLSKeyFile turn on HTTPS, both files are checked for
+	// changes every TLSReloadInterval, 0 turns the check off
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSReloadInterval time.Duration
+
+	ProductBackend Backend
+	UserBackend    Backend
+	CartBackend    Backend
+	OrderBackend   Backend
+
+	DiscoveryInterval         time.Duration
+	OutlierInterval           time.Duration
+	OutlierBaseEjectionTime   time.Duration
+	OutlierFailurePercent     int
+	OutlierMinRequests        int
+	OutlierMaxEjectionPercent int
+
+	ReloadInterval time.Duration
+}
+
+// Backend is where to find a backend service, how to spread calls across
+// its instances and how to secure the connection to them.
+type Backend struct {
+	// Addrs are the addresses of a static backend, or a single gRPC
+	// resolver target like dns:///orders:3004
+	Addrs []string
+	// Discovery is "static", "dns" or "file"
+	Discovery string
+	SRVName   string
+	AddrsFile string
+	// Balancer is "round_robin" or "least_request"
+	Balancer        string
+	OutlierEjection bool
+
+	TLS           bool
+	TLSCAFile     string
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSServerName string
+}
+
+// Load reads the configuration from the environment and the env file at
+// path, variables set in the environment take precedence over the file.
+func Load(path string) (*Config, error) {
+	file, err := godotenv.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	return env(file).config(), nil
+}
+
+// env holds the variables of the env file.
+type env map[string]string
+
+func (e env) config() *Config {
+	environment := e.get("ENVIRONMENT", EnvProduction)
+	// browsers drop Secure cookies over plain HTTP, so they are only the
+	// default when the gateway serves HTTPS or runs in production, where
+	// a proxy in front is expected to terminate TLS
+	secureDefault := strconv.FormatBool(e.get("TLS_CERT_FILE", "") != "" || environment == EnvProduction)
+	return &Config{
+		Secret:            e.get("SECRET", ""),
+		Environment:       environment,
+		LoaderConcurrency: e.getInt("LOADER_CONCURRENCY", 8),
+
+		LowStockThreshold:         int32(e.getInt("LOW_STOCK_THRESHOLD", 5)),
+		LowStockProductThresholds: parseThresholds(e.get("LOW_STOCK_PRODUCT_THRESHOLDS", "")),
+		LowStockWebhookURL:        e.get("LOW_STOCK_WEBHOOK_URL", ""),
+		LowStockWebhookSecret:     e.get("LOW_STOCK_WEBHOOK_SECRET", ""),
+		WebhookMaxAttempts:        e.getInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		WebhookRetryDelay:         e.getDuration("WEBHOOK_RETRY_DELAY", time.Second),
+		WebhookStorePath:          e.get("WEBHOOK_STORE_PATH", ""),
+
+		CacheEnabled: e.get("CACHE_ENABLED", "true") == "true",
+		CacheTTL:     e.getDuration("CACHE_TTL", 30*time.Second),
+		CacheSize:    e.getInt("CACHE_SIZE", 1000),
+
+		OrderStatusIDs: parseNameIDs(e.get("ORDER_STATUS_IDS", "")),
+		PaymentTypeIDs: parseNameIDs(e.get("PAYMENT_TYPE_IDS", "")),
+
+		OrderStockCheck: e.get("ORDER_STOCK_CHECK", "enforce"),
+
+		StreamMaxItems: e.getInt("STREAM_MAX_ITEMS", 10000),
+		StreamTimeout:  e.getDuration("STREAM_TIMEOUT", 15*time.Second),
+		StreamPolicy:   e.get("STREAM_POLICY", "fail"),
+
+		BatchMaxSize: e.getInt("BATCH_MAX_SIZE", 10),
+
+		CSRFTrustedOrigins: parseList(e.get("CSRF_TRUSTED_ORIGINS", "")),
+
+		SessionCookieName:       e.get("SESSION_COOKIE_NAME", "jwtToken"),
+		SessionCookieDomain:     e.get("SESSION_COOKIE_DOMAIN", ""),
+		SessionCookieSecure:     e.get("SESSION_COOKIE_SECURE", secureDefault) == "true",
+		SessionCookieSameSite:   e.get("SESSION_COOKIE_SAMESITE", "lax"),
+		SessionCookieHostPrefix: e.get("SESSION_COOKIE_HOST_PREFIX", "false") == "true",
+
+		CORSAllowedOrigins:   parseList(e.get("CORS_ALLOWED_ORIGINS", "")),
+		CORSAllowCredentials: e.get("CORS_ALLOW_CREDENTIALS", "false") == "true",
+		CORSAllowedHeaders:   parseList(e.get("CORS_ALLOWED_HEADERS", "Content-Type,Accept,X-CSRF-Protection")),
+		CORSMaxAge:           e.getDuration("CORS_MAX_AGE", 10*time.Minute),
+
+		ListenAddr:        e.get("LISTEN_ADDR", ":3001"),
+		AdminAddr:         e.get("ADMIN_ADDR", "127.0.0.1:3005"),
+		TLSCertFile:       e.get("TLS_CERT_FILE", ""),
+		TLSKeyFile:        e.get("TLS_KEY_FILE", ""),
+		TLSReloadInterval: e.getDuration("TLS_RELOAD_INTERVAL", time.Minute),
+
+		ProductBackend: e.backend("PRODUCT", "localhost:3000"),
+		UserBackend:    e.backend("USER", "localhost:3002"),
+		CartBackend:    e.backend("CART", "localhost:3003"),
+		OrderBackend:   e.backend("ORDER", "localhost:3004"),
+
+		DiscoveryInterval:         e.getDuration("DISCOVERY_INTERVAL", 30*time.Second),
+		OutlierInterval:           e.getDuration("OUTLIER_INTERVAL", 10*time.Second),
+		OutlierBaseEjectionTime:   e.getDuration("OUTLIER_BASE_EJECTION_TIME", 30*time.Second),
+		OutlierFailurePercent:     e.getInt("OUTLIER_FAILURE_PERCENT", 50),
+		OutlierMinRequests:        e.getInt("OUTLIER_MIN_REQUESTS", 10),
+		OutlierMaxEjectionPercent: e.getInt("OUTLIER_MAX_EJECTION_PERCENT", 50),
+
+		ReloadInterval: e.getDuration("CONFIG_RELOAD_INTERVAL", 10*time.Second),
+	}
+}
+
+// backend reads the settings of one backend from variables prefixed with
+// its name, like PRODUCT_SERVICE_ADDRS and PRODUCT_SERVICE_TLS_CA_FILE.
+func (e env) backend(name, addr string) Backend {
+	prefix := name + "_SERVICE_"
+	return Backend{
+		Addrs:           parseList(e.get(prefix+"ADDRS", addr)),
+		Discovery:       e.get(prefix+"DISCOVERY", "static"),
+		SRVName:         e.get(prefix+"SRV_NAME", ""),
+		AddrsFile:       e.get(prefix+"ADDRS_FILE", ""),
+		Balancer:        e.get(prefix+"BALANCER", "round_robin"),
+		OutlierEjection: e.get(prefix+"OUTLIER_EJECTION", "true") == "true",
+
+		TLS:           e.get(prefix+"TLS", "false") == "true",
+		TLSCAFile:     e.get(prefix+"TLS_CA_FILE", ""),
+		TLSCertFile:   e.get(prefix+"TLS_CERT_FILE", ""),
+		TLSKeyFile:    e.get(prefix+"TLS_KEY_FILE", ""),
+		TLSServerName: e.get(prefix+"TLS_SERVER_NAME", ""),
+	}
+}
+
+func (c *Config) IsDevelopment() bool {
+	return c.Environment == EnvDevelopment
+}
+
+func (e env) get(key, fallback string) string {
+	if val, ok := os.LookupEnv(key); ok && val != "" {
+		return val
+	}
+	if val := e[key]; val != "" {
+		return val
+	}
+	return fallback
+}
+
+func (e env) getInt(key string, fallback int) int {
+	val := e.get(key, "")
+	if val == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		log.Printf("invalid value %q for %s, using %d", val, key, fallback)
+		return fallback
+	}
+	return n
+}
+
+func (e env) getDuration(key string, fallback time.Duration) time.Duration {
+	val := e.get(key, "")
+	if val == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		log.Printf("invalid value %q for %s, using %s", val, key, fallback)
+		return fallback
+	}
+	return d
+}
+
+// parseThresholds parses per product thresholds written as
+// "productId:threshold,productId:threshold".
+func parseThresholds(val string) map[uint32]int32 {
+	thresholds := map[uint32]int32{}
+	for _, pair := range strings.Split(val, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		id, threshold, ok := strings.Cut(pair, ":")
+		productId, err := strconv.ParseUint(strings.TrimSpace(id), 10, 32)
+		if !ok || err != nil {
+			log.Printf("invalid low stock threshold %q", pair)
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(threshold), 10, 32)
+		if err != nil {
+			log.Printf("invalid low stock threshold %q", pair)
+			continue
+		}
+		thresholds[uint32(productId)] = int32(n)
+	}
+	return thresholds
+}
+
+// parseNameIDs parses enum id mappings written as "NAME:id,NAME:id".
+func parseNameIDs(val string) map[string]uint32 {
+	ids := map[string]uint32{}
+	for _, pair := range strings.Split(val, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, rawID, ok := strings.Cut(pair, ":")
+		id, err := strconv.ParseUint(strings.TrimSpace(rawID), 10, 32)
+		if !ok || err != nil {
+			log.Printf("invalid id mapping %q", pair)
+			continue
+		}
+		ids[strings.ToUpper(strings.TrimSpace(name))] = uint32(id)
+	}
+	return ids
+}
+
+// parseList parses a comma separated list, dropping empty entries.
+func parseList(val string) []string {
+	var list []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,303 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Validate reports the first setting that cannot be applied. A reload only
+// goes ahead when the whole new config is valid.
+func (c *Config) Validate() error {
+	switch c.OrderStockCheck {
+	case "enforce", "advisory", "off":
+	default:
+		return fmt.Errorf("ORDER_STOCK_CHECK: unknown mode %q", c.OrderStockCheck)
+	}
+	if c.StreamPolicy != "fail" && c.StreamPolicy != "partial" {
+		return fmt.Errorf("STREAM_POLICY: unknown policy %q", c.StreamPolicy)
+	}
+
+	switch strings.ToLower(c.SessionCookieSameSite) {
+	case "strict", "lax":
+	case "none":
+		if !c.SessionCookieSecure {
+			return errors.New("SESSION_COOKIE_SAMESITE: none needs SESSION_COOKIE_SECURE")
+		}
+	default:
+		return fmt.Errorf("SESSION_COOKIE_SAMESITE: unknown mode %q", c.SessionCookieSameSite)
+	}
+	if c.SessionCookieSecure && c.TLSCertFile == "" && c.Environment != EnvProduction {
+		log.Println("SESSION_COOKIE_SECURE: the session cookie is Secure but the gateway serves plain HTTP, browsers will not send it back")
+	}
+	if c.SessionCookieHostPrefix && (!c.SessionCookieSecure || c.SessionCookieDomain != "") {
+		return errors.New("SESSION_COOKIE_HOST_PREFIX: needs SESSION_COOKIE_SECURE and no SESSION_COOKIE_DOMAIN")
+	}
+	if c.CORSAllowCredentials {
+		for _, origin := range c.CORSAllowedOrigins {
+			if origin == "*" {
+				return errors.New("CORS_ALLOW_CREDENTIALS: cannot be combined with a * origin")
+			}
+		}
+	}
+
+	for key, interval := range map[string]time.Duration{
+		"CONFIG_RELOAD_INTERVAL":     c.ReloadInterval,
+		"DISCOVERY_INTERVAL":         c.DiscoveryInterval,
+		"OUTLIER_INTERVAL":           c.OutlierInterval,
+		"OUTLIER_BASE_EJECTION_TIME": c.OutlierBaseEjectionTime,
+		"CACHE_TTL":                  c.CacheTTL,
+	} {
+		if interval <= 0 {
+			return fmt.Errorf("%s: has to be positive, got %s", key, interval)
+		}
+	}
+	for key, count := range map[string]int{
+		"BATCH_MAX_SIZE":       c.BatchMaxSize,
+		"STREAM_MAX_ITEMS":     c.StreamMaxItems,
+		"CACHE_SIZE":           c.CacheSize,
+		"WEBHOOK_MAX_ATTEMPTS": c.WebhookMaxAttempts,
+	} {
+		if count <= 0 {
+			return fmt.Errorf("%s: has to be positive, got %d", key, count)
+		}
+	}
+	for key, percent := range map[string]int{
+		"OUTLIER_FAILURE_PERCENT":      c.OutlierFailurePercent,
+		"OUTLIER_MAX_EJECTION_PERCENT": c.OutlierMaxEjectionPercent,
+	} {
+		if percent <= 0 || percent > 100 {
+			return fmt.Errorf("%s: has to be between 1 and 100, got %d", key, percent)
+		}
+	}
+	if c.StreamTimeout <= 0 {
+		return fmt.Errorf("STREAM_TIMEOUT: has to be positive, got %s", c.StreamTimeout)
+	}
+	if c.LoaderConcurrency <= 0 {
+		return fmt.Errorf("LOADER_CONCURRENCY: has to be positive, got %d", c.LoaderConcurrency)
+	}
+	if c.TLSReloadInterval < 0 {
+		return fmt.Errorf("TLS_RELOAD_INTERVAL: cannot be negative, got %s", c.TLSReloadInterval)
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE have to be set together")
+	}
+	for name, backend := range c.backends() {
+		if err := backend.validate(); err != nil {
+			return fmt.Errorf("%s_SERVICE_%s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+func (b Backend) validate() error {
+	switch b.Discovery {
+	case "static":
+		if len(b.Addrs) == 0 {
+			return errors.New("ADDRS: no addresses")
+		}
+	case "dns":
+		if b.SRVName == "" {
+			return errors.New("SRV_NAME: needed for dns discovery")
+		}
+	case "file":
+		if b.AddrsFile == "" {
+			return errors.New("ADDRS_FILE: needed for file discovery")
+		}
+	default:
+		return fmt.Errorf("DISCOVERY: unknown discovery %q", b.Discovery)
+	}
+	if b.Balancer != "round_robin" && b.Balancer != "least_request" {
+		return fmt.Errorf("BALANCER: unknown balancer %q", b.Balancer)
+	}
+	if (b.TLSCertFile == "") != (b.TLSKeyFile == "") {
+		return errors.New("TLS_CERT_FILE: has to be set together with TLS_KEY_FILE")
+	}
+	return nil
+}
+
+func (c *Config) backends() map[string]Backend {
+	return map[string]Backend{
+		"PRODUCT": c.ProductBackend,
+		"USER":    c.UserBackend,
+		"CART":    c.CartBackend,
+		"ORDER":   c.OrderBackend,
+	}
+}
+
+// startupOnly holds the settings that are read once at startup, changing
+// them takes a restart.
+func (c *Config) startupOnly() map[string]interface{} {
+	settings := map[string]interface{}{
+		// the secret signs the session tokens, rotating it ends every
+		// session so it waits for a deliberate restart
+		"SECRET":                       c.Secret,
+		"ENVIRONMENT":                  c.Environment,
+		"LOW_STOCK_WEBHOOK_URL":        c.LowStockWebhookURL,
+		"LOW_STOCK_WEBHOOK_SECRET":     c.LowStockWebhookSecret,
+		"WEBHOOK_MAX_ATTEMPTS":         c.WebhookMaxAttempts,
+		"WEBHOOK_RETRY_DELAY":          c.WebhookRetryDelay,
+		"WEBHOOK_STORE_PATH":           c.WebhookStorePath,
+		"CACHE_ENABLED":                c.CacheEnabled,
+		"CACHE_SIZE":                   c.CacheSize,
+		"ORDER_STATUS_IDS":             c.OrderStatusIDs,
+		"PAYMENT_TYPE_IDS":             c.PaymentTypeIDs,
+		"LISTEN_ADDR":                  c.ListenAddr,
+		"ADMIN_ADDR":                   c.AdminAddr,
+		"TLS_CERT_FILE":                c.TLSCertFile,
+		"TLS_KEY_FILE":                 c.TLSKeyFile,
+		"TLS_RELOAD_INTERVAL":          c.TLSReloadInterval,
+		"DISCOVERY_INTERVAL":           c.DiscoveryInterval,
+		"OUTLIER_INTERVAL":             c.OutlierInterval,
+		"OUTLIER_BASE_EJECTION_TIME":   c.OutlierBaseEjectionTime,
+		"OUTLIER_FAILURE_PERCENT":      c.OutlierFailurePercent,
+		"OUTLIER_MIN_REQUESTS":         c.OutlierMinRequests,
+		"OUTLIER_MAX_EJECTION_PERCENT": c.OutlierMaxEjectionPercent,
+		"CONFIG_RELOAD_INTERVAL":       c.ReloadInterval,
+	}
+	for name, backend := range c.backends() {
+		// the addresses of a static backend are swapped in place, anything
+		// else about the connection is fixed once it is dialed
+		if !backend.StaticAddrs() {
+			settings[name+"_SERVICE_ADDRS"] = backend.Addrs
+		}
+		backend.Addrs = nil
+		settings[name+"_SERVICE"] = backend
+	}
+	return settings
+}
+
+// StaticAddrs reports whether Addrs is a fixed list of addresses rather than
+// a resolver target or unused.
+func (b Backend) StaticAddrs() bool {
+	return b.Discovery == "static" && !(len(b.Addrs) == 1 && strings.Contains(b.Addrs[0], "://"))
+}
+
+// RestartRequired lists the settings that differ between old and new but
+// are only applied at startup.
+func RestartRequired(old, new *Config) []string {
+	oldSettings, newSettings := old.startupOnly(), new.startupOnly()
+	var changed []string
+	for key, value := range newSettings {
+		if !reflect.DeepEqual(oldSettings[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
+
+// reloadStats counts config reloads, published through expvar as
+// "config_reloads".
+type reloadCounter struct {
+	mu         sync.Mutex
+	successes  int64
+	failures   int64
+	lastReload time.Time
+	lastError  string
+}
+
+var reloadStats = &reloadCounter{}
+
+func init() {
+	expvar.Publish("config_reloads", expvar.Func(reloadStats.snapshot))
+}
+
+func (s *reloadCounter) record(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failures++
+		s.lastError = err.Error()
+		return
+	}
+	s.successes++
+	s.lastReload = time.Now()
+	s.lastError = ""
+}
+
+func (s *reloadCounter) snapshot() interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]interface{}{
+		"successes":  s.successes,
+		"failures":   s.failures,
+		"lastReload": s.lastReload,
+		"lastError":  s.lastError,
+	}
+}
+
+// Watch reloads the config from path whenever the file changes or the
+// process gets SIGHUP, until ctx is done. A new config that loads and
+// validates is handed to apply, which swaps the reloadable settings. current
+// is the config the process started with.
+func Watch(ctx context.Context, path string, current *Config, apply func(*Config) error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(current.ReloadInterval)
+	defer ticker.Stop()
+	modTime := fileModTime(path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Println("reloading config on SIGHUP")
+		case <-ticker.C:
+			latest := fileModTime(path)
+			if !latest.After(modTime) {
+				continue
+			}
+			modTime = latest
+			log.Println("reloading config,", path, "changed")
+		}
+
+		next, err := reload(path, current, apply)
+		reloadStats.record(err)
+		if err != nil {
+			log.Println("config reload failed, keeping the current config:", err.Error())
+			continue
+		}
+		current = next
+		log.Println("config reloaded")
+	}
+}
+
+// reload loads and applies the config at path. Settings that need a restart
+// are reported once, when they differ from the last applied config.
+func reload(path string, current *Config, apply func(*Config) error) (*Config, error) {
+	next, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+	if err := apply(next); err != nil {
+		return nil, err
+	}
+	for _, key := range RestartRequired(current, next) {
+		log.Println(key, "changed, it is applied on the next restart")
+	}
+	return next, nil
+}
+
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
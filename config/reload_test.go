@@ -0,0 +1,90 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(c *Config)
+		wantErr string
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"stock check mode", func(c *Config) { c.OrderStockCheck = "sometimes" }, "ORDER_STOCK_CHECK"},
+		{"stream policy", func(c *Config) { c.StreamPolicy = "drop" }, "STREAM_POLICY"},
+		{"same site none over http", func(c *Config) {
+			c.SessionCookieSameSite = "None"
+			c.SessionCookieSecure = false
+		}, "SESSION_COOKIE_SAMESITE"},
+		{"credentials with any origin", func(c *Config) {
+			c.CORSAllowCredentials = true
+			c.CORSAllowedOrigins = []string{"*"}
+		}, "CORS_ALLOW_CREDENTIALS"},
+		{"zero interval", func(c *Config) { c.DiscoveryInterval = 0 }, "DISCOVERY_INTERVAL"},
+		{"negative stream timeout", func(c *Config) { c.StreamTimeout = -time.Second }, "STREAM_TIMEOUT"},
+		{"zero cache ttl", func(c *Config) { c.CacheTTL = 0 }, "CACHE_TTL"},
+		{"zero batch size", func(c *Config) { c.BatchMaxSize = 0 }, "BATCH_MAX_SIZE"},
+		{"negative stream items", func(c *Config) { c.StreamMaxItems = -1 }, "STREAM_MAX_ITEMS"},
+		{"zero cache size", func(c *Config) { c.CacheSize = 0 }, "CACHE_SIZE"},
+		{"zero webhook attempts", func(c *Config) { c.WebhookMaxAttempts = 0 }, "WEBHOOK_MAX_ATTEMPTS"},
+		{"zero failure percent", func(c *Config) { c.OutlierFailurePercent = 0 }, "OUTLIER_FAILURE_PERCENT"},
+		{"failure percent over 100", func(c *Config) { c.OutlierFailurePercent = 101 }, "OUTLIER_FAILURE_PERCENT"},
+		{"full ejection percent", func(c *Config) { c.OutlierMaxEjectionPercent = 100 }, ""},
+		{"ejection percent over 100", func(c *Config) { c.OutlierMaxEjectionPercent = 150 }, "OUTLIER_MAX_EJECTION_PERCENT"},
+		{"tls key without cert", func(c *Config) { c.TLSKeyFile = "key.pem" }, "TLS_CERT_FILE"},
+		{"backend without addresses", func(c *Config) { c.CartBackend.Addrs = nil }, "CART_SERVICE_ADDRS"},
+		{"backend balancer", func(c *Config) { c.UserBackend.Balancer = "random" }, "USER_SERVICE_BALANCER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := env{}.config()
+			tt.change(c)
+			err := c.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)):
+				t.Errorf("Validate() = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []string
+	}{
+		{"unchanged", func(c *Config) {}, nil},
+		{"reloadable settings", func(c *Config) {
+			c.StreamTimeout = time.Minute
+			c.CacheTTL = time.Minute
+			c.OrderStockCheck = "advisory"
+		}, nil},
+		{"secret", func(c *Config) { c.Secret = "rotated" }, []string{"SECRET"}},
+		{"startup settings", func(c *Config) {
+			c.CacheSize = 10
+			c.OutlierFailurePercent = 80
+		}, []string{"CACHE_SIZE", "OUTLIER_FAILURE_PERCENT"}},
+		{"static addresses", func(c *Config) { c.ProductBackend.Addrs = []string{"product:3000"} }, nil},
+		{"resolver target", func(c *Config) { c.ProductBackend.Addrs = []string{"dns:///product:3000"} }, []string{"PRODUCT_SERVICE_ADDRS"}},
+		{"backend connection", func(c *Config) { c.OrderBackend.TLS = true }, []string{"ORDER_SERVICE"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, next := env{}.config(), env{}.config()
+			tt.change(next)
+			got := RestartRequired(old, next)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RestartRequired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
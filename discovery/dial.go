@@ -0,0 +1,127 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/balancer/leastrequest"
+	"google.golang.org/grpc/balancer/roundrobin"
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	RoundRobin   = "round_robin"
+	LeastRequest = "least_request"
+)
+
+var balancers = map[string]string{
+	RoundRobin:   roundrobin.Name,
+	LeastRequest: "least_request_experimental",
+}
+
+type Options struct {
+	// Balancer is RoundRobin or LeastRequest.
+	Balancer string
+	// Outlier turns on outlier ejection when set.
+	Outlier *OutlierConfig
+}
+
+// Dial connects to the backend called name through the addresses source
+// yields, balancing calls across them. The source is watched until ctx is
+// done.
+func Dial(ctx context.Context, name string, source Source, opts Options, dialOpts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	b := &builder{scheme: "backend-" + strings.ToLower(name), source: source}
+	if opts.Outlier != nil {
+		b.ejector = newEjector(*opts.Outlier, b.refresh)
+		go b.ejector.run(ctx)
+		dialOpts = append(dialOpts,
+			grpc.WithContextDialer(b.ejector.dial),
+			grpc.WithChainUnaryInterceptor(b.ejector.unaryInterceptor),
+			grpc.WithChainStreamInterceptor(b.ejector.streamInterceptor),
+		)
+	}
+	dialOpts = append(dialOpts, grpc.WithResolvers(b))
+	return DialTarget(b.scheme+":///"+name, opts.Balancer, dialOpts...)
+}
+
+// DialTarget connects to a target resolved by gRPC itself, like
+// dns:///orders:3004.
+func DialTarget(target, balancer string, dialOpts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	policy, ok := balancers[balancer]
+	if !ok {
+		return nil, fmt.Errorf("unknown balancer %q", balancer)
+	}
+	serviceConfig := fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}]}`, policy)
+	return grpc.Dial(target, append(dialOpts, grpc.WithDefaultServiceConfig(serviceConfig))...)
+}
+
+// builder hands the addresses of its source, minus the ejected ones, to the
+// client connection.
+type builder struct {
+	scheme  string
+	source  Source
+	ejector *ejector
+
+	mu     sync.Mutex
+	cc     resolver.ClientConn
+	addrs  []string
+	cancel context.CancelFunc
+}
+
+func (b *builder) Scheme() string {
+	return b.scheme
+}
+
+func (b *builder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.mu.Lock()
+	b.cc = cc
+	b.cancel = cancel
+	b.mu.Unlock()
+	go b.source.Watch(ctx, func(addrs []string) {
+		b.mu.Lock()
+		b.addrs = addrs
+		b.mu.Unlock()
+		if b.ejector != nil {
+			b.ejector.setAddrs(addrs)
+		}
+		b.refresh()
+	})
+	return b, nil
+}
+
+func (b *builder) refresh() {
+	b.mu.Lock()
+	cc, addrs := b.cc, b.addrs
+	b.mu.Unlock()
+	if cc == nil {
+		return
+	}
+	if b.ejector != nil {
+		addrs = b.ejector.healthy()
+	}
+	state := resolver.State{Addresses: make([]resolver.Address, len(addrs))}
+	for i, addr := range addrs {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		state.Addresses[i] = resolver.Address{Addr: addr, ServerName: host}
+	}
+	cc.UpdateState(state)
+}
+
+func (b *builder) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (b *builder) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.cc = nil
+}
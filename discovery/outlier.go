@@ -0,0 +1,250 @@
+package discovery
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// OutlierConfig sets when an address is taken out of rotation. Every
+// Interval the addresses that served at least MinRequests calls, of which
+// FailurePercent or more failed, are ejected for BaseEjectionTime times the
+// number of times they were ejected before. No more than MaxEjectionPercent
+// of the addresses are ejected at once.
+type OutlierConfig struct {
+	Interval           time.Duration
+	BaseEjectionTime   time.Duration
+	FailurePercent     int
+	MinRequests        int
+	MaxEjectionPercent int
+}
+
+type addrStats struct {
+	requests     int
+	failures     int
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// ejector counts the calls to every address and decides which ones are
+// ejected. changed is called whenever that set changes.
+type ejector struct {
+	cfg     OutlierConfig
+	changed func()
+
+	mu    sync.Mutex
+	addrs []string
+	stats map[string]*addrStats
+}
+
+func newEjector(cfg OutlierConfig, changed func()) *ejector {
+	return &ejector{cfg: cfg, changed: changed, stats: map[string]*addrStats{}}
+}
+
+func (e *ejector) setAddrs(addrs []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.addrs = addrs
+	stats := make(map[string]*addrStats, len(addrs))
+	for _, addr := range addrs {
+		if s, ok := e.stats[addr]; ok {
+			stats[addr] = s
+		} else {
+			stats[addr] = &addrStats{}
+		}
+	}
+	e.stats = stats
+}
+
+// healthy returns the addresses that are not ejected.
+func (e *ejector) healthy() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	var addrs []string
+	for _, addr := range e.addrs {
+		if e.stats[addr].ejectedUntil.Before(now) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func (e *ejector) record(addr string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, ok := e.stats[addr]
+	if !ok {
+		return
+	}
+	s.requests++
+	if isFailure(err) {
+		s.failures++
+	}
+}
+
+// isFailure reports whether err says something about the backend rather than
+// about the call. Calls whose own context ended are not recorded at all, see
+// recordCall, a DeadlineExceeded counted here is one the backend or the
+// connection ran into.
+func isFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
+
+func (e *ejector) run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if e.evaluate(time.Now()) {
+			e.changed()
+		}
+	}
+}
+
+// evaluate ejects the outliers of the last interval and lets the addresses
+// whose ejection is over back in. It reports whether the ejected set changed.
+func (e *ejector) evaluate(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	changed := false
+	ejected := 0
+	for _, s := range e.stats {
+		if s.ejectedUntil.IsZero() {
+			continue
+		}
+		if s.ejectedUntil.After(now) {
+			ejected++
+			continue
+		}
+		s.ejectedUntil = time.Time{}
+		changed = true
+	}
+
+	maxEjected := len(e.addrs) * e.cfg.MaxEjectionPercent / 100
+	for _, addr := range e.addrs {
+		s := e.stats[addr]
+		requests, failures := s.requests, s.failures
+		s.requests, s.failures = 0, 0
+		if !s.ejectedUntil.IsZero() || requests == 0 || requests < e.cfg.MinRequests {
+			continue
+		}
+		if failures*100 < requests*e.cfg.FailurePercent {
+			if s.ejections > 0 {
+				s.ejections--
+			}
+			continue
+		}
+		if ejected >= maxEjected {
+			continue
+		}
+		s.ejections++
+		s.ejectedUntil = now.Add(e.cfg.BaseEjectionTime * time.Duration(s.ejections))
+		ejected++
+		changed = true
+		log.Printf("ejected %s for %s, %d of %d calls failed", addr, e.cfg.BaseEjectionTime*time.Duration(s.ejections), failures, requests)
+	}
+	return changed
+}
+
+// dial keeps the address the resolver gave on the connection, the calls
+// made over it are then recorded for that address and not for the IP it
+// resolved to.
+func (e *ejector) dial(ctx context.Context, addr string) (net.Conn, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &namedConn{Conn: conn, addr: namedAddr{Addr: conn.RemoteAddr(), name: addr}}, nil
+}
+
+type namedConn struct {
+	net.Conn
+	addr namedAddr
+}
+
+func (c *namedConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+type namedAddr struct {
+	net.Addr
+	name string
+}
+
+func (a namedAddr) String() string {
+	return a.name
+}
+
+// recordCall records the outcome of a call made with ctx to the peer p. A
+// call that failed after ctx was cancelled or ran out, like one bounded by
+// the stream timeout of the gateway, says nothing about the backend and is
+// left out.
+func (e *ejector) recordCall(ctx context.Context, p *peer.Peer, err error) {
+	if p.Addr == nil {
+		return
+	}
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	e.record(p.Addr.String(), err)
+}
+
+func (e *ejector) unaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	var p peer.Peer
+	err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(&p))...)
+	e.recordCall(ctx, &p, err)
+	return err
+}
+
+func (e *ejector) streamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	p := &peer.Peer{}
+	stream, err := streamer(ctx, desc, cc, method, append(opts, grpc.Peer(p))...)
+	if err != nil {
+		e.recordCall(ctx, p, err)
+		return nil, err
+	}
+	return &recordedStream{ClientStream: stream, ejector: e, ctx: ctx, peer: p}, nil
+}
+
+// recordedStream records the outcome of a stream once it ends. ctx is the
+// context of the caller, the context of the stream itself is cancelled once
+// the stream ends.
+type recordedStream struct {
+	grpc.ClientStream
+	ejector *ejector
+	ctx     context.Context
+	peer    *peer.Peer
+	once    sync.Once
+}
+
+func (s *recordedStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.once.Do(func() {
+			if err == io.EOF {
+				s.ejector.recordCall(s.ctx, s.peer, nil)
+			} else {
+				s.ejector.recordCall(s.ctx, s.peer, err)
+			}
+		})
+	}
+	return err
+}
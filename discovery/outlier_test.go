@@ -0,0 +1,159 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var testOutlierConfig = OutlierConfig{
+	Interval:           10 * time.Second,
+	BaseEjectionTime:   30 * time.Second,
+	FailurePercent:     50,
+	MinRequests:        5,
+	MaxEjectionPercent: 50,
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		addrs []string
+		// stats of the addresses before the evaluation, and for how much
+		// longer they were ejected then.
+		before     map[string]addrStats
+		ejectedFor map[string]time.Duration
+		// ejection time and count of every address after it.
+		wantEjected   map[string]time.Duration
+		wantEjections map[string]int
+		wantChanged   bool
+	}{
+		{
+			name:          "healthy",
+			addrs:         []string{"a", "b"},
+			before:        map[string]addrStats{"a": {requests: 10, failures: 4}},
+			wantEjected:   map[string]time.Duration{},
+			wantEjections: map[string]int{"a": 0},
+		},
+		{
+			name:          "too few requests",
+			addrs:         []string{"a", "b"},
+			before:        map[string]addrStats{"a": {requests: 4, failures: 4}},
+			wantEjected:   map[string]time.Duration{},
+			wantEjections: map[string]int{"a": 0},
+		},
+		{
+			name:          "first ejection",
+			addrs:         []string{"a", "b"},
+			before:        map[string]addrStats{"a": {requests: 10, failures: 5}},
+			wantEjected:   map[string]time.Duration{"a": 30 * time.Second},
+			wantEjections: map[string]int{"a": 1},
+			wantChanged:   true,
+		},
+		{
+			name:          "ejection time grows",
+			addrs:         []string{"a", "b"},
+			before:        map[string]addrStats{"a": {requests: 10, failures: 10, ejections: 2}},
+			wantEjected:   map[string]time.Duration{"a": 90 * time.Second},
+			wantEjections: map[string]int{"a": 3},
+			wantChanged:   true,
+		},
+		{
+			name:          "healthy interval shrinks the ejection count",
+			addrs:         []string{"a", "b"},
+			before:        map[string]addrStats{"a": {requests: 10, failures: 0, ejections: 2}},
+			wantEjected:   map[string]time.Duration{},
+			wantEjections: map[string]int{"a": 1},
+		},
+		{
+			name:  "max ejection cap",
+			addrs: []string{"a", "b", "c", "d"},
+			before: map[string]addrStats{
+				"a": {requests: 10, failures: 10},
+				"b": {requests: 10, failures: 10},
+				"c": {requests: 10, failures: 10},
+			},
+			wantEjected:   map[string]time.Duration{"a": 30 * time.Second, "b": 30 * time.Second},
+			wantEjections: map[string]int{"a": 1, "b": 1, "c": 0},
+			wantChanged:   true,
+		},
+		{
+			name:  "ejected addresses count against the cap",
+			addrs: []string{"a", "b", "c", "d"},
+			before: map[string]addrStats{
+				"a": {ejections: 1},
+				"b": {requests: 10, failures: 10},
+				"c": {requests: 10, failures: 10},
+			},
+			ejectedFor:    map[string]time.Duration{"a": 20 * time.Second},
+			wantEjected:   map[string]time.Duration{"a": 20 * time.Second, "b": 30 * time.Second},
+			wantEjections: map[string]int{"a": 1, "b": 1, "c": 0},
+			wantChanged:   true,
+		},
+		{
+			name:          "ejection is over",
+			addrs:         []string{"a", "b"},
+			before:        map[string]addrStats{"a": {ejections: 1}},
+			ejectedFor:    map[string]time.Duration{"a": -time.Second},
+			wantEjected:   map[string]time.Duration{},
+			wantEjections: map[string]int{"a": 1},
+			wantChanged:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEjector(testOutlierConfig, func() {})
+			e.setAddrs(tt.addrs)
+			for addr, s := range tt.before {
+				s := s
+				if d, ok := tt.ejectedFor[addr]; ok {
+					s.ejectedUntil = now.Add(d)
+				}
+				e.stats[addr] = &s
+			}
+
+			if changed := e.evaluate(now); changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			for _, addr := range tt.addrs {
+				s := e.stats[addr]
+				want, ejected := tt.wantEjected[addr]
+				switch {
+				case ejected && s.ejectedUntil.Sub(now) != want:
+					t.Errorf("%s ejected for %v, want %v", addr, s.ejectedUntil.Sub(now), want)
+				case !ejected && !s.ejectedUntil.IsZero():
+					t.Errorf("%s ejected until %v, want not ejected", addr, s.ejectedUntil)
+				}
+				if want, ok := tt.wantEjections[addr]; ok && s.ejections != want {
+					t.Errorf("%s ejections = %d, want %d", addr, s.ejections, want)
+				}
+				if s.requests != 0 || s.failures != 0 {
+					t.Errorf("%s counters were not reset: %+v", addr, s)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordCall(t *testing.T) {
+	p := &peer.Peer{Addr: namedAddr{Addr: &net.TCPAddr{}, name: "a"}}
+	deadline := status.Error(codes.DeadlineExceeded, "context deadline exceeded")
+
+	e := newEjector(testOutlierConfig, func() {})
+	e.setAddrs([]string{"a"})
+	e.recordCall(context.Background(), p, deadline)
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	e.recordCall(expired, p, deadline)
+	e.recordCall(expired, &peer.Peer{}, deadline)
+
+	if s := e.stats["a"]; s.requests != 1 || s.failures != 1 {
+		t.Errorf("stats = %+v, want only the deadline the backend ran into", s)
+	}
+}
@@ -0,0 +1,159 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source yields the addresses of a backend service. Watch calls update with
+// the full list every time it changes, until ctx is done.
+type Source interface {
+	Watch(ctx context.Context, update func(addrs []string))
+}
+
+// List is a list of addresses that can be replaced while it is watched.
+type List struct {
+	mu       sync.Mutex
+	addrs    []string
+	watchers map[*func([]string)]struct{}
+}
+
+func NewList(addrs []string) *List {
+	return &List{addrs: addrs, watchers: map[*func([]string)]struct{}{}}
+}
+
+// Set replaces the addresses, the watchers get the new list when it differs
+// from the current one.
+func (l *List) Set(addrs []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if sameAddrs(addrs, l.addrs) {
+		return
+	}
+	l.addrs = addrs
+	for update := range l.watchers {
+		(*update)(addrs)
+	}
+}
+
+func (l *List) Watch(ctx context.Context, update func(addrs []string)) {
+	l.mu.Lock()
+	l.watchers[&update] = struct{}{}
+	update(l.addrs)
+	l.mu.Unlock()
+
+	<-ctx.Done()
+	l.mu.Lock()
+	delete(l.watchers, &update)
+	l.mu.Unlock()
+}
+
+// DNSSRV looks up the SRV records of Name every Interval, Name is the full
+// record name like _grpc._tcp.orders.example.com.
+type DNSSRV struct {
+	Name     string
+	Interval time.Duration
+}
+
+func (s DNSSRV) Watch(ctx context.Context, update func(addrs []string)) {
+	poll(ctx, s.Interval, update, func() ([]string, error) {
+		_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", s.Name)
+		if err != nil {
+			return nil, err
+		}
+		addrs := make([]string, len(records))
+		for i, record := range records {
+			addrs[i] = net.JoinHostPort(strings.TrimSuffix(record.Target, "."), fmt.Sprint(record.Port))
+		}
+		return addrs, nil
+	})
+}
+
+// File reads one address per line from Path and reads it again whenever it
+// changes. Blank lines and lines starting with # are skipped.
+type File struct {
+	Path     string
+	Interval time.Duration
+}
+
+func (s File) Watch(ctx context.Context, update func(addrs []string)) {
+	var modTime time.Time
+	var last []string
+	poll(ctx, s.Interval, update, func() ([]string, error) {
+		info, err := os.Stat(s.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.ModTime().After(modTime) {
+			return last, nil
+		}
+		f, err := os.Open(s.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		var addrs []string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			addrs = append(addrs, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		modTime, last = info.ModTime(), addrs
+		return addrs, nil
+	})
+}
+
+// poll runs lookup every interval and calls update when the addresses
+// changed. A failed or empty lookup keeps the addresses found before.
+func poll(ctx context.Context, interval time.Duration, update func([]string), lookup func() ([]string, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var current []string
+	for {
+		addrs, err := lookup()
+		switch {
+		case err != nil:
+			log.Println("discovery failed:", err.Error())
+		case len(addrs) == 0:
+			log.Println("discovery found no addresses, keeping", current)
+		case !sameAddrs(addrs, current):
+			current = addrs
+			update(addrs)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sameAddrs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
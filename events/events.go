@@ -0,0 +1,86 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	TopicOrder    = "order"
+	TopicLowStock = "low_stock"
+)
+
+const (
+	OrderPlaced        = "PLACED"
+	OrderCancelled     = "CANCELLED"
+	OrderStatusChanged = "STATUS_CHANGED"
+)
+
+// OrderEvent is published whenever an order mutation succeeds through the
+// gateway. UserId is zero when the owner of the order is not known.
+type OrderEvent struct {
+	Type       string    `json:"type"`
+	OrderId    uint32    `json:"orderId"`
+	StatusId   uint32    `json:"orderStatusId"`
+	UserId     uint32    `json:"userId"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+// Bus is an in process publish/subscribe bus. Slow subscribers do not block
+// publishers, events are dropped for a subscriber whose buffer is full.
+type Bus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[string]map[int]chan interface{}
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subs: make(map[string]map[int]chan interface{}),
+	}
+}
+
+// Subscribe returns a channel receiving every event published on topic and a
+// function that cancels the subscription and closes the channel.
+func (b *Bus) Subscribe(topic string, buffer int) (<-chan interface{}, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	ch := make(chan interface{}, buffer)
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[int]chan interface{})
+	}
+	b.subs[topic][id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs[topic], id)
+			close(ch)
+		})
+	}
+}
+
+func (b *Bus) Publish(topic string, event interface{}) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, ch := range b.subs[topic] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// LowStockEvent is published when a product goes below its stock threshold.
+type LowStockEvent struct {
+	ProductId  uint32    `json:"productId"`
+	Name       string    `json:"name"`
+	Quantity   int32     `json:"quantity"`
+	Threshold  int32     `json:"threshold"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
@@ -5,6 +5,7 @@ go 1.21.0
 require (
 	github.com/Nishad4140/proto_files v0.0.0-20240216085049-edae94a07903
 	github.com/golang-jwt/jwt v3.2.2+incompatible
+	github.com/gorilla/websocket v1.5.1
 	github.com/graphql-go/graphql v0.8.1
 	github.com/joho/godotenv v1.5.1
 )
@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"strconv"
+
+	"github.com/Nishad4140/api_gateway/cache"
+	"github.com/Nishad4140/api_gateway/settings"
+	"github.com/Nishad4140/proto_files/pb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	productCachePrefix = "product:"
+	productsCacheKey   = "products:all"
+	usersCacheKey      = "users:all"
+)
+
+var ResponseCache cache.Cache
+
+// InitCache enables caching of the public catalog reads, a nil cache turns it
+// off.
+func InitCache(c cache.Cache) {
+	ResponseCache = c
+}
+
+func cacheGet(key string, value interface{}) bool {
+	if ResponseCache == nil {
+		return false
+	}
+	data, ok := ResponseCache.Get(key)
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		log.Println("cache:", err.Error())
+		return false
+	}
+	return true
+}
+
+func cacheSet(ctx context.Context, key string, value interface{}) {
+	if ResponseCache == nil {
+		return
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Println("cache:", err.Error())
+		return
+	}
+	ResponseCache.Set(key, data, settings.From(ctx).CacheTTL)
+}
+
+// invalidateProducts drops the cached catalog and the given products, or
+// every cached product when no id is given. It is called by every mutation
+// that changes products.
+func invalidateProducts(ids ...uint32) {
+	if ResponseCache == nil {
+		return
+	}
+	if len(ids) == 0 {
+		ResponseCache.DeletePrefix(productCachePrefix)
+	}
+	keys := []string{productsCacheKey}
+	for _, id := range ids {
+		keys = append(keys, productCachePrefix+strconv.Itoa(int(id)))
+	}
+	ResponseCache.Delete(keys...)
+}
+
+func cachedProduct(ctx context.Context, id uint32) (*pb.AddProductResponse, error) {
+	key := productCachePrefix + strconv.Itoa(int(id))
+	var prod pb.AddProductResponse
+	if cacheGet(key, &prod) {
+		return &prod, nil
+	}
+	res, err := getProductByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cacheSet(ctx, key, res)
+	return res, nil
+}
+
+// cachedProducts returns the cached catalog, ok is false on a cache miss.
+func cachedProducts() ([]*pb.AddProductResponse, bool) {
+	var products []*pb.AddProductResponse
+	if !cacheGet(productsCacheKey, &products) {
+		return nil, false
+	}
+	return products, true
+}
+
+// allProducts returns the whole catalog from the cache or drains the product
+// stream and caches it. On error the products received so far are returned
+// and nothing is cached.
+func allProducts(ctx context.Context) ([]*pb.AddProductResponse, error) {
+	if products, ok := cachedProducts(); ok {
+		return products, nil
+	}
+	opts := streamOptions(ctx)
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	stream, err := ProductsConn.GetAllProducts(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	products, err := collectStream(stream.Recv, opts.MaxItems)
+	if err != nil {
+		return products, err
+	}
+	cacheSet(ctx, productsCacheKey, products)
+	return products, nil
+}
+
+// sliceRecv replays items with the same signature as a stream Recv.
+func sliceRecv[T any](items []T) func() (T, error) {
+	i := 0
+	return func() (T, error) {
+		var zero T
+		if i >= len(items) {
+			return zero, io.EOF
+		}
+		i++
+		return items[i-1], nil
+	}
+}
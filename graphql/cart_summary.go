@@ -0,0 +1,238 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/Nishad4140/api_gateway/middleware"
+	"github.com/Nishad4140/proto_files/pb"
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	WarningPriceChanged      = "PRICE_CHANGED"
+	WarningOutOfStock        = "OUT_OF_STOCK"
+	WarningInsufficientStock = "INSUFFICIENT_STOCK"
+	WarningUnavailable       = "UNAVAILABLE"
+)
+
+// blockingWarnings are the warnings that make OrderAll fail for the cart.
+var blockingWarnings = map[string]bool{
+	WarningOutOfStock:        true,
+	WarningInsufficientStock: true,
+	WarningUnavailable:       true,
+}
+
+type cartSummaryItem struct {
+	ProductId    uint32   `json:"productId"`
+	Name         string   `json:"name"`
+	Quantity     int32    `json:"quantity"`
+	CartPrice    float64  `json:"cartPrice"`
+	CurrentPrice *float64 `json:"currentPrice"`
+	LineTotal    float64  `json:"lineTotal"`
+	InStock      int32    `json:"inStock"`
+	PriceChanged bool     `json:"priceChanged"`
+	Available    bool     `json:"available"`
+}
+
+type cartWarning struct {
+	Code      string `json:"code"`
+	ProductId uint32 `json:"productId"`
+	Message   string `json:"message"`
+}
+
+type cartSummary struct {
+	Items         []*cartSummaryItem `json:"items"`
+	ItemCount     int32              `json:"itemCount"`
+	Subtotal      float64            `json:"subtotal"`
+	Warnings      []*cartWarning     `json:"warnings"`
+	CheckoutReady bool               `json:"checkoutReady"`
+}
+
+var CartWarningCodeEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "CartWarningCode",
+	Values: graphql.EnumValueConfigMap{
+		WarningPriceChanged:      &graphql.EnumValueConfig{Value: WarningPriceChanged},
+		WarningOutOfStock:        &graphql.EnumValueConfig{Value: WarningOutOfStock},
+		WarningInsufficientStock: &graphql.EnumValueConfig{Value: WarningInsufficientStock},
+		WarningUnavailable:       &graphql.EnumValueConfig{Value: WarningUnavailable},
+	},
+})
+
+var CartWarningType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "cartWarning",
+		Fields: graphql.Fields{
+			"code": &graphql.Field{
+				Type: graphql.NewNonNull(CartWarningCodeEnum),
+			},
+			"productId": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"message": &graphql.Field{
+				Type: graphql.String,
+			},
+		},
+	},
+)
+
+var CartSummaryItemType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "cartSummaryItem",
+		Fields: graphql.Fields{
+			"productId": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"name": &graphql.Field{
+				Type: graphql.String,
+			},
+			"quantity": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"cartPrice": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "unit price when the item was added to the cart",
+			},
+			"currentPrice": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "unit price now, null if the product no longer exists",
+			},
+			"lineTotal": &graphql.Field{
+				Type: graphql.Float,
+			},
+			"inStock": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"priceChanged": &graphql.Field{
+				Type: graphql.Boolean,
+			},
+			"available": &graphql.Field{
+				Type: graphql.Boolean,
+			},
+		},
+	},
+)
+
+var CartSummaryType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "cartSummary",
+		Fields: graphql.Fields{
+			"items": &graphql.Field{
+				Type: graphql.NewList(CartSummaryItemType),
+			},
+			"itemCount": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"subtotal": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "sum of the line totals at current prices",
+			},
+			"warnings": &graphql.Field{
+				Type: graphql.NewList(CartWarningType),
+			},
+			"checkoutReady": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "false when a warning would make OrderAll fail",
+			},
+		},
+	},
+)
+
+func resolveCartSummary(p graphql.ResolveParams) (interface{}, error) {
+	userId := middleware.PrincipalFrom(p.Context).UserId
+	return buildCartSummary(p.Context, uint32(userId))
+}
+
+// cartRows drains the cart of userId within the stream limits.
+func cartRows(ctx context.Context, userId uint32) ([]*pb.GetAllCartResponse, error) {
+	opts := streamOptions(ctx)
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	stream, err := CartConn.GetAllCart(ctx, &pb.CartCreate{
+		UserId: userId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return collectStream(stream.Recv, opts.MaxItems)
+}
+
+// buildCartSummary joins the cart of userId with the current products. The
+// cart service stores the line total at the time the item was added, so the
+// unit price it was added at is total / quantity.
+func buildCartSummary(ctx context.Context, userId uint32) (*cartSummary, error) {
+	rows, err := cartRows(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	products := loadersFrom(ctx).Products
+	thunks := make([]func() (*pb.AddProductResponse, error), len(rows))
+	for i, row := range rows {
+		thunks[i] = products.Load(ctx, row.ProductId)
+	}
+
+	summary := &cartSummary{
+		Items:         make([]*cartSummaryItem, 0, len(rows)),
+		Warnings:      []*cartWarning{},
+		CheckoutReady: true,
+	}
+	warn := func(code string, productId uint32, format string, args ...interface{}) {
+		summary.Warnings = append(summary.Warnings, &cartWarning{
+			Code:      code,
+			ProductId: productId,
+			Message:   fmt.Sprintf(format, args...),
+		})
+		if blockingWarnings[code] {
+			summary.CheckoutReady = false
+		}
+	}
+
+	for i, row := range rows {
+		item := &cartSummaryItem{
+			ProductId: row.ProductId,
+			Quantity:  row.Quantity,
+		}
+		if row.Quantity > 0 {
+			item.CartPrice = roundPrice(float64(row.Total) / float64(row.Quantity))
+		}
+		summary.Items = append(summary.Items, item)
+		summary.ItemCount += row.Quantity
+
+		prod, err := thunks[i]()
+		if err != nil {
+			if apperror.Convert(err).Code != apperror.CodeNotFound {
+				return nil, err
+			}
+			warn(WarningUnavailable, row.ProductId, "product %d is no longer available", row.ProductId)
+			continue
+		}
+
+		price := float64(prod.Price)
+		item.Name = prod.Name
+		item.CurrentPrice = &price
+		item.InStock = prod.Quantity
+		item.Available = prod.Quantity >= row.Quantity
+		item.LineTotal = roundPrice(price * float64(row.Quantity))
+		summary.Subtotal += item.LineTotal
+
+		if item.CartPrice != price {
+			item.PriceChanged = true
+			warn(WarningPriceChanged, row.ProductId, "price of %s changed from %.2f to %.2f", prod.Name, item.CartPrice, price)
+		}
+		switch {
+		case prod.Quantity <= 0:
+			warn(WarningOutOfStock, row.ProductId, "%s is out of stock", prod.Name)
+		case prod.Quantity < row.Quantity:
+			warn(WarningInsufficientStock, row.ProductId, "only %d of %s left in stock", prod.Quantity, prod.Name)
+		}
+	}
+	summary.Subtotal = roundPrice(summary.Subtotal)
+	return summary, nil
+}
+
+func roundPrice(price float64) float64 {
+	return math.Round(price*100) / 100
+}
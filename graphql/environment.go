@@ -0,0 +1,19 @@
+package graph
+
+import (
+	"github.com/Nishad4140/api_gateway/middleware"
+	"github.com/graphql-go/graphql"
+)
+
+var Development bool
+
+// ConfigureEnvironment switches the schema between development and production
+// behaviour. Outside development introspection is limited to super admins.
+func ConfigureEnvironment(development bool) {
+	Development = development
+	if development {
+		return
+	}
+	graphql.SchemaMetaFieldDef.Resolve = middleware.SupAdminMiddleware(graphql.SchemaMetaFieldDef.Resolve)
+	graphql.TypeMetaFieldDef.Resolve = middleware.SupAdminMiddleware(graphql.TypeMetaFieldDef.Resolve)
+}
@@ -0,0 +1,77 @@
+package graph
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// requestErrors are the messages graphql-go uses for requests it cannot pick
+// an operation from. They are raised as plain errors, not as *gqlerrors.Error.
+var requestErrors = []string{
+	"Must provide operation name",
+	"Must provide an operation",
+	"Unknown operation named",
+	"GraphQL cannot execute a request containing",
+	"Can only execute queries",
+}
+
+// FormatError maps resolver errors to the apperror catalog so that every
+// error carries extensions.code and a safe message. Internal details are only
+// logged, in development they are returned as the message. Syntax and
+// validation errors are passed through as is.
+func FormatError(err error) gqlerrors.FormattedError {
+	formatted := gqlerrors.FormatError(err)
+	gqlErr, ok := err.(*gqlerrors.Error)
+	if (ok && gqlErr.OriginalError == nil) || (!ok && isRequestError(err)) {
+		formatted.Extensions = map[string]interface{}{
+			"code": string(apperror.CodeValidationFailed),
+		}
+		return formatted
+	}
+
+	// errors returned by subscribe functions arrive as they are, field
+	// errors wrapped in a *gqlerrors.Error
+	original := err
+	var path []interface{}
+	if ok {
+		original, path = gqlErr.OriginalError, gqlErr.Path
+	}
+	appErr := apperror.Convert(unwrapFormatted(original))
+	if appErr.Internal != nil {
+		log.Printf("graphql error: path=%v code=%s: %v", path, appErr.Code, appErr.Internal)
+	}
+	formatted.Message = appErr.Message
+	if Development && appErr.Internal != nil {
+		formatted.Message = appErr.Internal.Error()
+	}
+	formatted.Extensions = appErr.Extensions()
+	return formatted
+}
+
+// unwrapFormatted returns the error a thunk failed with. graphql-go panics
+// with thunk errors wrapped in a FormattedError, which does not unwrap.
+func unwrapFormatted(err error) error {
+	for {
+		var formatted gqlerrors.FormattedError
+		if !errors.As(err, &formatted) || formatted.OriginalError() == nil {
+			return err
+		}
+		err = formatted.OriginalError()
+		if gqlErr, ok := err.(*gqlerrors.Error); ok && gqlErr.OriginalError != nil {
+			err = gqlErr.OriginalError
+		}
+	}
+}
+
+func isRequestError(err error) bool {
+	for _, prefix := range requestErrors {
+		if strings.HasPrefix(err.Error(), prefix) {
+			return true
+		}
+	}
+	return false
+}
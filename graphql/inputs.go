@@ -0,0 +1,93 @@
+package graph
+
+import (
+	"github.com/Nishad4140/api_gateway/validation"
+	"github.com/graphql-go/graphql"
+)
+
+const maxQuantity = 100000
+
+var SignUpInput = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "SignUpInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"email": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"password": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+	},
+)
+
+var ProductInput = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "ProductInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"price": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.Int),
+			},
+			"quantity": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.Int),
+			},
+		},
+	},
+)
+
+var CartItemInput = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "CartItemInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"productId": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.Int),
+			},
+			"quantity": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.Int),
+			},
+		},
+	},
+)
+
+var (
+	signUpRules = validation.Rules{
+		"name":     {validation.Required(), validation.Length(2, 100)},
+		"email":    {validation.Required(), validation.Length(3, 254), validation.Email()},
+		"password": {validation.Required(), validation.Length(8, 72), validation.Password()},
+	}
+	productRules = validation.Rules{
+		"name":     {validation.Required(), validation.Length(1, 200)},
+		"price":    {validation.Required(), validation.Range(0, 100000000)},
+		"quantity": {validation.Required(), validation.Range(0, maxQuantity)},
+	}
+	cartItemRules = validation.Rules{
+		"productId": {validation.Required(), validation.Range(1, 1<<31-1)},
+		"quantity":  {validation.Required(), validation.Range(1, maxQuantity)},
+	}
+	stockRules = validation.Rules{
+		"stock": {validation.Required(), validation.Range(0, maxQuantity)},
+	}
+)
+
+// inputArgs merges the fields of the input argument over the older scalar
+// arguments, so mutations accept either form.
+func inputArgs(args map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(args))
+	for name, value := range args {
+		if name != "input" {
+			values[name] = value
+		}
+	}
+	if input, ok := args["input"].(map[string]interface{}); ok {
+		for name, value := range input {
+			values[name] = value
+		}
+	}
+	return values
+}
@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/Nishad4140/api_gateway/loader"
+	"github.com/Nishad4140/api_gateway/settings"
+	"github.com/Nishad4140/proto_files/pb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Loaders holds the per request loaders, the /graphql handler attaches a
+// fresh set to every request context under "loaders".
+type Loaders struct {
+	Products *loader.Loader[uint32, *pb.AddProductResponse]
+	Users    *loader.Loader[uint32, *pb.UserResponse]
+}
+
+func NewLoaders(ctx context.Context) *Loaders {
+	return &Loaders{
+		Products: loader.New("products", loader.Concurrent(settings.From(ctx).LoaderConcurrency, getProductByID)),
+		Users:    loader.New("users", batchUsers),
+	}
+}
+
+func loadersFrom(ctx context.Context) *Loaders {
+	if loaders, ok := ctx.Value("loaders").(*Loaders); ok {
+		return loaders
+	}
+	return NewLoaders(ctx)
+}
+
+// getProductByID fetches a single product from the product service.
+func getProductByID(ctx context.Context, id uint32) (*pb.AddProductResponse, error) {
+	return ProductsConn.GetProduct(ctx, &pb.GetProductByID{
+		Id: id,
+	})
+}
+
+// batchUsers resolves the requested users from the user directory, the user
+// service has no lookup by id. The directory is scanned once and cached for
+// CacheTTL so that requests share the scan. A user missing from the cached
+// directory, like one who just signed up, makes the batch scan again.
+func batchUsers(ctx context.Context, ids []uint32) []loader.Result[*pb.UserResponse] {
+	results := make([]loader.Result[*pb.UserResponse], len(ids))
+	var users []*pb.UserResponse
+	if cacheGet(usersCacheKey, &users) && fillUsers(results, ids, users) {
+		return results
+	}
+
+	users, err := allUsers(ctx)
+	if err != nil {
+		for i := range results {
+			results[i] = loader.Result[*pb.UserResponse]{Err: err}
+		}
+		return results
+	}
+	cacheSet(ctx, usersCacheKey, users)
+	fillUsers(results, ids, users)
+	return results
+}
+
+// fillUsers sets the result of every id to its user in users, or to not found.
+// It reports whether every user was found.
+func fillUsers(results []loader.Result[*pb.UserResponse], ids []uint32, users []*pb.UserResponse) bool {
+	byID := make(map[uint32]*pb.UserResponse, len(users))
+	for _, user := range users {
+		byID[user.Id] = user
+	}
+	found := true
+	for i, id := range ids {
+		user, ok := byID[id]
+		if !ok {
+			results[i] = loader.Result[*pb.UserResponse]{Err: apperror.New(apperror.CodeNotFound, "user not found")}
+			found = false
+			continue
+		}
+		results[i] = loader.Result[*pb.UserResponse]{Value: user}
+	}
+	return found
+}
+
+// allUsers drains the user stream within the stream limits.
+func allUsers(ctx context.Context) ([]*pb.UserResponse, error) {
+	opts := streamOptions(ctx)
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	users, err := UsersConn.GetAllUsers(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	return collectStream(users.Recv, opts.MaxItems)
+}
+
+func loadProduct(ctx context.Context, id uint32) func() (interface{}, error) {
+	thunk := loadersFrom(ctx).Products.Load(ctx, id)
+	return func() (interface{}, error) {
+		return thunk()
+	}
+}
+
+func loadUser(ctx context.Context, id uint32) func() (interface{}, error) {
+	thunk := loadersFrom(ctx).Users.Load(ctx, id)
+	return func() (interface{}, error) {
+		return thunk()
+	}
+}
@@ -0,0 +1,105 @@
+package graph
+
+import (
+	"context"
+	"io"
+
+	"github.com/Nishad4140/api_gateway/middleware"
+	"github.com/Nishad4140/proto_files/pb"
+	"github.com/graphql-go/graphql"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	RoleUser       = "USER"
+	RoleAdmin      = "ADMIN"
+	RoleSuperAdmin = "SUPER_ADMIN"
+)
+
+var RoleEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "Role",
+	Values: graphql.EnumValueConfigMap{
+		RoleUser:       &graphql.EnumValueConfig{Value: RoleUser},
+		RoleAdmin:      &graphql.EnumValueConfig{Value: RoleAdmin},
+		RoleSuperAdmin: &graphql.EnumValueConfig{Value: RoleSuperAdmin},
+	},
+})
+
+var MeType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "Me",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*middleware.Principal).UserId, nil
+				},
+			},
+			"roles": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(RoleEnum))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return principalRoles(p.Source.(*middleware.Principal)), nil
+				},
+			},
+			"expiresAt": &graphql.Field{
+				Type: graphql.DateTime,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*middleware.Principal).ExpiresAt, nil
+				},
+			},
+			"user": &graphql.Field{
+				Type:    UserType,
+				Resolve: resolveMeUser,
+			},
+		},
+	},
+)
+
+func principalRoles(principal *middleware.Principal) []string {
+	roles := []string{RoleUser}
+	if principal.IsAdmin {
+		roles = append(roles, RoleAdmin)
+	}
+	if principal.IsSuAdmin {
+		roles = append(roles, RoleSuperAdmin)
+	}
+	return roles
+}
+
+// resolveMe returns the principal UserMiddleware put on the context, the
+// profile itself is fetched lazily by the user field.
+func resolveMe(p graphql.ResolveParams) (interface{}, error) {
+	return middleware.PrincipalFrom(p.Context), nil
+}
+
+// resolveMeUser looks the caller up in the user service. Users go through
+// the user loader, admins are only listed by GetAllAdmins. Super admins are
+// not listed by either and resolve to null.
+func resolveMeUser(p graphql.ResolveParams) (interface{}, error) {
+	principal := p.Source.(*middleware.Principal)
+	if !principal.IsAdmin {
+		return loadUser(p.Context, uint32(principal.UserId)), nil
+	}
+	return findAdmin(p.Context, uint32(principal.UserId))
+}
+
+func findAdmin(ctx context.Context, id uint32) (*pb.UserResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	admins, err := UsersConn.GetAllAdmins(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		admin, err := admins.Recv()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if admin.Id == id {
+			return admin, nil
+		}
+	}
+}
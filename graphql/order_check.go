@@ -0,0 +1,55 @@
+package graph
+
+import (
+	"context"
+	"log"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/Nishad4140/api_gateway/settings"
+)
+
+// The stock check modes decide what OrderAll does when the cart cannot be
+// served from stock. Advisory lets the order through and reports the
+// problems as warnings.
+const (
+	StockCheckEnforce  = "enforce"
+	StockCheckAdvisory = "advisory"
+	StockCheckOff      = "off"
+)
+
+// checkCartStock validates the cart of userId against the current stock
+// before the order is placed, the order service only finds out halfway
+// through. The blocking problems are listed under "problems" in the error
+// extensions. In advisory mode the order goes ahead and they are sent as
+// warnings instead.
+func checkCartStock(ctx context.Context, userId uint32) error {
+	mode := settings.From(ctx).StockCheck
+	if mode == StockCheckOff {
+		return nil
+	}
+	summary, err := buildCartSummary(ctx, userId)
+	if err != nil {
+		return err
+	}
+	var problems []*cartWarning
+	for _, warning := range summary.Warnings {
+		if blockingWarnings[warning.Code] {
+			problems = append(problems, warning)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	if mode == StockCheckAdvisory {
+		for _, problem := range problems {
+			log.Printf("order stock check: user %d: %s: %s", userId, problem.Code, problem.Message)
+			addWarning(ctx, problem)
+		}
+		return nil
+	}
+	return &apperror.Error{
+		Code:    apperror.CodeFailedPrecondition,
+		Message: "some items in the cart cannot be ordered",
+		Details: map[string]interface{}{"problems": problems},
+	}
+}
@@ -0,0 +1,166 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/Nishad4140/proto_files/pb"
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	StatusPending   = "PENDING"
+	StatusConfirmed = "CONFIRMED"
+	StatusShipped   = "SHIPPED"
+	StatusDelivered = "DELIVERED"
+	StatusCancelled = "CANCELLED"
+	StatusReturned  = "RETURNED"
+)
+
+// the ids the order service uses, overridable with ConfigureOrderEnums
+var (
+	OrderStatusIDs = map[string]uint32{
+		StatusPending:   1,
+		StatusConfirmed: 2,
+		StatusShipped:   3,
+		StatusDelivered: 4,
+		StatusCancelled: 5,
+		StatusReturned:  6,
+	}
+	PaymentTypeIDs = map[string]uint32{
+		"COD":    1,
+		"ONLINE": 2,
+		"WALLET": 3,
+	}
+)
+
+// orderTransitions lists the statuses an order may move to from each status.
+var orderTransitions = map[string][]string{
+	StatusPending:   {StatusConfirmed, StatusShipped, StatusCancelled},
+	StatusConfirmed: {StatusShipped, StatusCancelled},
+	StatusShipped:   {StatusDelivered},
+	StatusDelivered: {StatusReturned},
+}
+
+// ConfigureOrderEnums replaces the id mappings of the order status and
+// payment type enums. Names that are not enum values are ignored.
+func ConfigureOrderEnums(statusIDs, paymentIDs map[string]uint32) {
+	if len(statusIDs) > 0 {
+		OrderStatusIDs = knownNames(OrderStatusEnum, statusIDs)
+	}
+	if len(paymentIDs) > 0 {
+		PaymentTypeIDs = knownNames(PaymentTypeEnum, paymentIDs)
+	}
+}
+
+func knownNames(enum *graphql.Enum, ids map[string]uint32) map[string]uint32 {
+	known := make(map[string]uint32, len(ids))
+	for name, id := range ids {
+		if enum.ParseValue(name) == nil {
+			continue
+		}
+		known[name] = id
+	}
+	return known
+}
+
+func nameForID(ids map[string]uint32, id uint32) (string, bool) {
+	for name, candidate := range ids {
+		if candidate == id {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func newEnum(name string, values ...string) *graphql.Enum {
+	config := graphql.EnumValueConfigMap{}
+	for _, value := range values {
+		config[value] = &graphql.EnumValueConfig{
+			Value: value,
+		}
+	}
+	return graphql.NewEnum(graphql.EnumConfig{
+		Name:   name,
+		Values: config,
+	})
+}
+
+var OrderStatusEnum = newEnum("OrderStatus", StatusPending, StatusConfirmed, StatusShipped, StatusDelivered, StatusCancelled, StatusReturned)
+
+var PaymentTypeEnum = newEnum("PaymentType", "COD", "ONLINE", "WALLET")
+
+type orderStatusRef interface {
+	GetOrderStatusId() uint32
+}
+
+type paymentTypeRef interface {
+	GetPaymentTypeId() uint32
+}
+
+func resolveOrderStatus(p graphql.ResolveParams) (interface{}, error) {
+	source, ok := p.Source.(orderStatusRef)
+	if !ok {
+		return nil, nil
+	}
+	if name, ok := nameForID(OrderStatusIDs, source.GetOrderStatusId()); ok {
+		return name, nil
+	}
+	return nil, nil
+}
+
+func resolvePaymentType(p graphql.ResolveParams) (interface{}, error) {
+	source, ok := p.Source.(paymentTypeRef)
+	if !ok {
+		return nil, nil
+	}
+	if name, ok := nameForID(PaymentTypeIDs, source.GetPaymentTypeId()); ok {
+		return name, nil
+	}
+	return nil, nil
+}
+
+// checkOrderTransition fetches the current status of the order and rejects
+// moving it to target when the state machine does not allow it. An order in a
+// status the gateway has no mapping for cannot be moved, the state machine
+// cannot tell whether the move is allowed.
+func checkOrderTransition(ctx context.Context, orderId uint32, target string) error {
+	order, err := OrderConn.GetOrder(ctx, &pb.OrderId{
+		OrderId: orderId,
+	})
+	if err != nil {
+		return err
+	}
+	current, ok := nameForID(OrderStatusIDs, order.OrderStatusId)
+	if !ok {
+		return apperror.New(apperror.CodeFailedPrecondition, fmt.Sprintf("order is in unknown status %d", order.OrderStatusId))
+	}
+	for _, allowed := range orderTransitions[current] {
+		if allowed == target {
+			return nil
+		}
+	}
+	return apperror.New(apperror.CodeFailedPrecondition, fmt.Sprintf("order cannot move from %s to %s", current, target))
+}
+
+// orderStatusArg reads the target status of ChangeOrderStatus from either the
+// status enum or the deprecated statusId. A statusId has to map to a
+// configured status.
+func orderStatusArg(args map[string]interface{}) (uint32, string, error) {
+	if status, ok := args["status"].(string); ok {
+		id, ok := OrderStatusIDs[status]
+		if !ok {
+			return 0, "", apperror.New(apperror.CodeBadUserInput, fmt.Sprintf("order status %s is not configured", status))
+		}
+		return id, status, nil
+	}
+	if statusId, ok := args["statusId"].(int); ok {
+		name, ok := nameForID(OrderStatusIDs, uint32(statusId))
+		if !ok {
+			return 0, "", apperror.New(apperror.CodeBadUserInput, fmt.Sprintf("order status id %d is not configured", statusId))
+		}
+		return uint32(statusId), name, nil
+	}
+	return 0, "", apperror.New(apperror.CodeBadUserInput, "status is required")
+}
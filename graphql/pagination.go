@@ -0,0 +1,280 @@
+package graph
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	cursorPrefix = "offset:"
+	maxPageSize  = 100
+)
+
+var PageInfoType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+			},
+			"hasPreviousPage": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+			},
+			"startCursor": &graphql.Field{
+				Type: graphql.String,
+			},
+			"endCursor": &graphql.Field{
+				Type: graphql.String,
+			},
+		},
+	},
+)
+
+// ConnectionArgs are the relay pagination arguments shared by every
+// connection field.
+var ConnectionArgs = graphql.FieldConfigArgument{
+	"first": &graphql.ArgumentConfig{
+		Type: graphql.Int,
+	},
+	"after": &graphql.ArgumentConfig{
+		Type: graphql.String,
+	},
+	"last": &graphql.ArgumentConfig{
+		Type: graphql.Int,
+	},
+	"before": &graphql.ArgumentConfig{
+		Type: graphql.String,
+	},
+}
+
+// NewConnectionType builds the relay connection type for nodeType, named
+// <name>Connection with edges of type <name>Edge.
+func NewConnectionType(name string, nodeType graphql.Output) *graphql.Object {
+	edgeType := graphql.NewObject(
+		graphql.ObjectConfig{
+			Name: name + "Edge",
+			Fields: graphql.Fields{
+				"cursor": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"node": &graphql.Field{
+					Type: nodeType,
+				},
+			},
+		},
+	)
+	return graphql.NewObject(
+		graphql.ObjectConfig{
+			Name: name + "Connection",
+			Fields: graphql.Fields{
+				"edges": &graphql.Field{
+					Type: graphql.NewList(edgeType),
+				},
+				"pageInfo": &graphql.Field{
+					Type: graphql.NewNonNull(PageInfoType),
+				},
+				"totalCount": &graphql.Field{
+					Type: graphql.Int,
+				},
+			},
+		},
+	)
+}
+
+func encodeCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, apperror.New(apperror.CodeBadUserInput, "invalid cursor")
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, apperror.New(apperror.CodeBadUserInput, "invalid cursor")
+	}
+	return offset, nil
+}
+
+type pageArgs struct {
+	first, last   int
+	after, before int
+	hasFirst      bool
+	hasLast       bool
+	hasBefore     bool
+}
+
+func parsePageArgs(args map[string]interface{}) (pageArgs, error) {
+	page := pageArgs{after: -1}
+	if first, ok := args["first"].(int); ok {
+		if first < 0 || first > maxPageSize {
+			return page, apperror.New(apperror.CodeBadUserInput, fmt.Sprintf("first must be between 0 and %d", maxPageSize))
+		}
+		page.first, page.hasFirst = first, true
+	}
+	if last, ok := args["last"].(int); ok {
+		if last < 0 || last > maxPageSize {
+			return page, apperror.New(apperror.CodeBadUserInput, fmt.Sprintf("last must be between 0 and %d", maxPageSize))
+		}
+		page.last, page.hasLast = last, true
+	}
+	if after, ok := args["after"].(string); ok {
+		offset, err := decodeCursor(after)
+		if err != nil {
+			return page, err
+		}
+		page.after = offset
+	}
+	if before, ok := args["before"].(string); ok {
+		offset, err := decodeCursor(before)
+		if err != nil {
+			return page, err
+		}
+		page.before, page.hasBefore = offset, true
+	}
+	return page, nil
+}
+
+// stopAt returns how many items have to be received from the stream to
+// answer the page, or -1 when the whole stream is needed.
+func (page pageArgs) stopAt() int {
+	switch {
+	case page.hasBefore:
+		return page.before
+	case page.hasLast:
+		return -1
+	case page.hasFirst:
+		// one extra item tells whether there is a next page, cursors are
+		// client input so the sum saturates instead of overflowing
+		if page.after > math.MaxInt-page.first-2 {
+			return math.MaxInt
+		}
+		return page.after + 1 + page.first + 1
+	default:
+		return -1
+	}
+}
+
+// errPageRead ends the stream of a connection once the page has been read.
+var errPageRead = errors.New("page read")
+
+// NewConnection pages through a gRPC server stream using offset cursors. The
+// stream is only read as far as the requested page unless totalCount is
+// selected, and never past the configured stream limit. A broken stream is
+// handled by the stream policy like any other list. Callers should cancel the
+// stream context once this returns.
+func NewConnection[T any](p graphql.ResolveParams, recv func() (T, error)) (interface{}, error) {
+	page, err := parsePageArgs(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	stop := page.stopAt()
+	if selectsField(p, "totalCount") {
+		stop = -1
+	}
+
+	received := 0
+	items, err := collectStream(func() (T, error) {
+		if stop >= 0 && received >= stop {
+			var zero T
+			return zero, errPageRead
+		}
+		received++
+		return recv()
+	}, streamOptions(p.Context).MaxItems)
+	exhausted := err == nil
+	if err != nil && err != errPageRead {
+		if items, err = partialList(p, items, err); err != nil {
+			return nil, err
+		}
+	}
+
+	start := len(items)
+	if page.after < len(items) {
+		start = page.after + 1
+	}
+	end := len(items)
+	if page.hasBefore && page.before < end {
+		end = page.before
+	}
+	if end < start {
+		end = start
+	}
+	if page.hasFirst && end-start > page.first {
+		end = start + page.first
+	}
+	if page.hasLast && end-start > page.last {
+		start = end - page.last
+	}
+
+	edges := make([]map[string]interface{}, 0, end-start)
+	for i := start; i < end; i++ {
+		edges = append(edges, map[string]interface{}{
+			"cursor": encodeCursor(i),
+			"node":   items[i],
+		})
+	}
+
+	pageInfo := map[string]interface{}{
+		"hasPreviousPage": start > 0,
+		"hasNextPage":     end < len(items) || !exhausted,
+		"startCursor":     nil,
+		"endCursor":       nil,
+	}
+	if len(edges) > 0 {
+		pageInfo["startCursor"] = edges[0]["cursor"]
+		pageInfo["endCursor"] = edges[len(edges)-1]["cursor"]
+	}
+
+	connection := map[string]interface{}{
+		"edges":      edges,
+		"pageInfo":   pageInfo,
+		"totalCount": nil,
+	}
+	if exhausted {
+		connection["totalCount"] = len(items)
+	}
+	return connection, nil
+}
+
+// selectsField reports whether the current field selects name directly. When
+// fragments are involved it conservatively reports true.
+func selectsField(p graphql.ResolveParams, name string) bool {
+	for _, fieldAST := range p.Info.FieldASTs {
+		if fieldAST.SelectionSet == nil {
+			continue
+		}
+		for _, selection := range fieldAST.SelectionSet.Selections {
+			switch selection := selection.(type) {
+			case *ast.Field:
+				if selection.Name != nil && selection.Name.Value == name {
+					return true
+				}
+			default:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MergeArgs combines several argument sets into one for a single field.
+func MergeArgs(argSets ...graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	merged := graphql.FieldConfigArgument{}
+	for _, args := range argSets {
+		for name, arg := range args {
+			merged[name] = arg
+		}
+	}
+	return merged
+}
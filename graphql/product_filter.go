@@ -0,0 +1,211 @@
+package graph
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/Nishad4140/proto_files/pb"
+	"github.com/graphql-go/graphql"
+)
+
+var SortDirectionEnum = graphql.NewEnum(
+	graphql.EnumConfig{
+		Name: "SortDirection",
+		Values: graphql.EnumValueConfigMap{
+			"ASC": &graphql.EnumValueConfig{
+				Value: "ASC",
+			},
+			"DESC": &graphql.EnumValueConfig{
+				Value: "DESC",
+			},
+		},
+	},
+)
+
+var ProductSortFieldEnum = graphql.NewEnum(
+	graphql.EnumConfig{
+		Name: "ProductSortField",
+		Values: graphql.EnumValueConfigMap{
+			"PRICE": &graphql.EnumValueConfig{
+				Value: "PRICE",
+			},
+			"NAME": &graphql.EnumValueConfig{
+				Value: "NAME",
+			},
+			"QUANTITY": &graphql.EnumValueConfig{
+				Value: "QUANTITY",
+			},
+		},
+	},
+)
+
+var ProductFilterInput = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "ProductFilterInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"nameContains": &graphql.InputObjectFieldConfig{
+				Type: graphql.String,
+			},
+			"minPrice": &graphql.InputObjectFieldConfig{
+				Type: graphql.Int,
+			},
+			"maxPrice": &graphql.InputObjectFieldConfig{
+				Type: graphql.Int,
+			},
+			"inStock": &graphql.InputObjectFieldConfig{
+				Type: graphql.Boolean,
+			},
+		},
+	},
+)
+
+var ProductSortInput = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "ProductSortInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"field": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(ProductSortFieldEnum),
+			},
+			"direction": &graphql.InputObjectFieldConfig{
+				Type:         SortDirectionEnum,
+				DefaultValue: "ASC",
+			},
+		},
+	},
+)
+
+type productFilter struct {
+	nameContains string
+	minPrice     *int
+	maxPrice     *int
+	inStock      bool
+}
+
+type productSort struct {
+	field string
+	desc  bool
+}
+
+func parseProductFilter(args map[string]interface{}) (*productFilter, error) {
+	input, ok := args["filter"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	filter := &productFilter{}
+	if name, ok := input["nameContains"].(string); ok {
+		filter.nameContains = strings.ToLower(name)
+	}
+	if minPrice, ok := input["minPrice"].(int); ok {
+		filter.minPrice = &minPrice
+	}
+	if maxPrice, ok := input["maxPrice"].(int); ok {
+		filter.maxPrice = &maxPrice
+	}
+	if inStock, ok := input["inStock"].(bool); ok {
+		filter.inStock = inStock
+	}
+	if (filter.minPrice != nil && *filter.minPrice < 0) || (filter.maxPrice != nil && *filter.maxPrice < 0) {
+		return nil, apperror.New(apperror.CodeBadUserInput, "price range cannot be negative")
+	}
+	if filter.minPrice != nil && filter.maxPrice != nil && *filter.minPrice > *filter.maxPrice {
+		return nil, apperror.New(apperror.CodeBadUserInput, "minPrice cannot be greater than maxPrice")
+	}
+	return filter, nil
+}
+
+func parseProductSort(args map[string]interface{}) *productSort {
+	input, ok := args["sort"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	field, _ := input["field"].(string)
+	direction, _ := input["direction"].(string)
+	return &productSort{field: field, desc: direction == "DESC"}
+}
+
+func (f *productFilter) match(prod *pb.AddProductResponse) bool {
+	if f == nil {
+		return true
+	}
+	if f.nameContains != "" && !strings.Contains(strings.ToLower(prod.GetName()), f.nameContains) {
+		return false
+	}
+	if f.minPrice != nil && int(prod.GetPrice()) < *f.minPrice {
+		return false
+	}
+	if f.maxPrice != nil && int(prod.GetPrice()) > *f.maxPrice {
+		return false
+	}
+	if f.inStock && prod.GetQuantity() <= 0 {
+		return false
+	}
+	return true
+}
+
+func (s *productSort) apply(products []*pb.AddProductResponse) {
+	if s == nil {
+		return
+	}
+	less := func(a, b *pb.AddProductResponse) bool {
+		switch s.field {
+		case "NAME":
+			return strings.ToLower(a.GetName()) < strings.ToLower(b.GetName())
+		case "QUANTITY":
+			return a.GetQuantity() < b.GetQuantity()
+		default:
+			return a.GetPrice() < b.GetPrice()
+		}
+	}
+	sort.SliceStable(products, func(i, j int) bool {
+		if s.desc {
+			return less(products[j], products[i])
+		}
+		return less(products[i], products[j])
+	})
+}
+
+// productRecv wraps a product stream so that it only yields products that
+// match the filter. Sorting needs every product, so when sort is set the
+// stream is drained, up to maxItems products, and the sorted products are
+// replayed. When the stream breaks while it is drained, the products
+// received so far are replayed sorted and then the error, so that the caller
+// applies the stream policy as for any other broken stream.
+func productRecv(recv func() (*pb.AddProductResponse, error), filter *productFilter, order *productSort, maxItems int) func() (*pb.AddProductResponse, error) {
+	filtered := func() (*pb.AddProductResponse, error) {
+		for {
+			prod, err := recv()
+			if err != nil {
+				return nil, err
+			}
+			if filter.match(prod) {
+				return prod, nil
+			}
+		}
+	}
+	if order == nil {
+		return filtered
+	}
+
+	products, err := collectStream(filtered, maxItems)
+	order.apply(products)
+	sorted := sliceRecv(products)
+	return func() (*pb.AddProductResponse, error) {
+		prod, recvErr := sorted()
+		if recvErr == io.EOF && err != nil {
+			return nil, err
+		}
+		return prod, recvErr
+	}
+}
+
+// ProductListArgs are the filter and sort arguments of the product lists.
+var ProductListArgs = graphql.FieldConfigArgument{
+	"filter": &graphql.ArgumentConfig{
+		Type: ProductFilterInput,
+	},
+	"sort": &graphql.ArgumentConfig{
+		Type: ProductSortInput,
+	},
+}
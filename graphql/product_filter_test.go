@@ -0,0 +1,77 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Nishad4140/api_gateway/settings"
+	"github.com/Nishad4140/proto_files/pb"
+	"github.com/graphql-go/graphql"
+)
+
+func testProducts(quantities ...int32) []*pb.AddProductResponse {
+	products := make([]*pb.AddProductResponse, len(quantities))
+	for i, quantity := range quantities {
+		products[i] = &pb.AddProductResponse{Id: uint32(i + 1), Quantity: quantity}
+	}
+	return products
+}
+
+// sortedConnection pages the first two products sorted by quantity through
+// a stream of products limited to maxItems, under policy.
+func sortedConnection(t *testing.T, policy string, maxItems int, products []*pb.AddProductResponse) (map[string]interface{}, *partialErrors, error) {
+	t.Helper()
+	s := *settings.Current()
+	s.Streams.Policy = policy
+	s.Streams.MaxItems = maxItems
+	errs := &partialErrors{}
+	ctx := context.WithValue(settings.NewContext(context.Background(), &s), partialErrorsKey{}, errs)
+
+	recv := productRecv(sliceRecv(products), nil, &productSort{field: "QUANTITY"}, maxItems)
+	result, err := NewConnection(graphql.ResolveParams{Context: ctx, Args: map[string]interface{}{"first": 2}}, recv)
+	if err != nil {
+		return nil, errs, err
+	}
+	return result.(map[string]interface{}), errs, nil
+}
+
+func TestSortedConnection(t *testing.T) {
+	connection, errs, err := sortedConnection(t, StreamFail, 10, testProducts(3, 1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges := connection["edges"].([]map[string]interface{})
+	if len(edges) != 2 || edges[0]["node"].(*pb.AddProductResponse).Id != 2 || edges[1]["node"].(*pb.AddProductResponse).Id != 3 {
+		t.Errorf("edges = %v, want products 2 and 3", edges)
+	}
+	if hasNext := connection["pageInfo"].(map[string]interface{})["hasNextPage"]; hasNext != true {
+		t.Errorf("hasNextPage = %v, want true", hasNext)
+	}
+	if len(errs.errs) != 0 {
+		t.Errorf("partial errors = %v, want none", errs.errs)
+	}
+}
+
+func TestSortedConnectionOverLimitFails(t *testing.T) {
+	if _, _, err := sortedConnection(t, StreamFail, 2, testProducts(3, 1, 2)); err == nil {
+		t.Error("sorting more products than the limit did not fail")
+	}
+}
+
+func TestSortedConnectionOverLimitPartial(t *testing.T) {
+	connection, errs, err := sortedConnection(t, StreamPartial, 2, testProducts(3, 1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the products received before the limit are sorted
+	edges := connection["edges"].([]map[string]interface{})
+	if len(edges) != 2 || edges[0]["node"].(*pb.AddProductResponse).Id != 2 || edges[1]["node"].(*pb.AddProductResponse).Id != 1 {
+		t.Errorf("edges = %v, want products 2 and 1", edges)
+	}
+	if connection["totalCount"] != nil {
+		t.Errorf("totalCount = %v, want null for a partial list", connection["totalCount"])
+	}
+	if len(errs.errs) != 1 {
+		t.Errorf("partial errors = %v, want the truncation", errs.errs)
+	}
+}
@@ -0,0 +1,29 @@
+package graph
+
+import (
+	"github.com/graphql-go/graphql"
+)
+
+type productRef interface {
+	GetProductId() uint32
+}
+
+type userRef interface {
+	GetUserId() uint32
+}
+
+func resolveCartProduct(p graphql.ResolveParams) (interface{}, error) {
+	item, ok := p.Source.(productRef)
+	if !ok || item.GetProductId() == 0 {
+		return nil, nil
+	}
+	return loadProduct(p.Context, item.GetProductId()), nil
+}
+
+func resolveCartUser(p graphql.ResolveParams) (interface{}, error) {
+	item, ok := p.Source.(userRef)
+	if !ok || item.GetUserId() == 0 {
+		return nil, nil
+	}
+	return loadUser(p.Context, item.GetUserId()), nil
+}
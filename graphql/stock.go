@@ -0,0 +1,93 @@
+package graph
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Nishad4140/api_gateway/events"
+	"github.com/Nishad4140/api_gateway/settings"
+	"github.com/Nishad4140/proto_files/pb"
+	"github.com/graphql-go/graphql"
+)
+
+func stockThreshold(ctx context.Context, productId uint32) int32 {
+	lowStock := settings.From(ctx).LowStock
+	if threshold, ok := lowStock.PerProduct[productId]; ok {
+		return threshold
+	}
+	return lowStock.Threshold
+}
+
+// checkLowStock publishes a low stock event when the stock of prod went from
+// previous to below its threshold. A product that stays below its threshold
+// is not reported again.
+func checkLowStock(ctx context.Context, prod *pb.AddProductResponse, previous int32) {
+	if prod == nil {
+		return
+	}
+	threshold := stockThreshold(ctx, prod.Id)
+	if prod.Quantity >= threshold || previous < threshold {
+		return
+	}
+	EventBus.Publish(events.TopicLowStock, events.LowStockEvent{
+		ProductId:  prod.Id,
+		Name:       prod.Name,
+		Quantity:   prod.Quantity,
+		Threshold:  threshold,
+		OccurredAt: time.Now(),
+	})
+}
+
+// cartQuantities returns the quantity of every product in the cart of userId.
+func cartQuantities(ctx context.Context, userId uint32) (map[uint32]int32, error) {
+	rows, err := cartRows(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	quantities := make(map[uint32]int32, len(rows))
+	for _, row := range rows {
+		quantities[row.ProductId] += row.Quantity
+	}
+	return quantities, nil
+}
+
+// checkOrderStock checks the stock of the products of an order that was just
+// placed, ordered holds the quantities of the cart before the order. It runs
+// in the background so it never delays the order response.
+func checkOrderStock(ordered map[uint32]int32) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for productId, quantity := range ordered {
+		prod, err := getProductByID(ctx, productId)
+		if err != nil {
+			log.Println("low stock check:", err.Error())
+			continue
+		}
+		checkLowStock(ctx, prod, prod.Quantity+quantity)
+	}
+}
+
+var LowStockEventType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "lowStockEvent",
+		Fields: graphql.Fields{
+			"productId": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"name": &graphql.Field{
+				Type: graphql.String,
+			},
+			"quantity": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"threshold": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"occurredAt": &graphql.Field{
+				Type: graphql.DateTime,
+			},
+		},
+	},
+)
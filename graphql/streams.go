@@ -0,0 +1,150 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/Nishad4140/api_gateway/incremental"
+	"github.com/Nishad4140/api_gateway/settings"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+const (
+	// StreamFail fails the whole field when a stream breaks.
+	StreamFail = "fail"
+	// StreamPartial returns the items received so far and reports the error
+	// next to the data.
+	StreamPartial = "partial"
+)
+
+func streamOptions(ctx context.Context) settings.Streams {
+	return settings.From(ctx).Streams
+}
+
+// collectStream drains recv until io.EOF, the first error or maxItems items.
+// The items received before an error are returned along with it.
+func collectStream[T any](recv func() (T, error), maxItems int) ([]T, error) {
+	var items []T
+	for {
+		item, err := recv()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return items, err
+		}
+		if maxItems > 0 && len(items) >= maxItems {
+			return items, apperror.New(apperror.CodeFailedPrecondition, fmt.Sprintf("list truncated after %d items", maxItems))
+		}
+		items = append(items, item)
+	}
+}
+
+// resolveStream opens a stream bounded by the configured timeout and collects
+// it for a list resolver. Under @stream only the initial items are collected
+// and the incremental handler takes over the stream.
+func resolveStream[T any](p graphql.ResolveParams, open func(ctx context.Context) (func() (T, error), error)) (interface{}, error) {
+	opts := streamOptions(p.Context)
+	ctx, cancel := context.WithTimeout(p.Context, opts.Timeout)
+	recv, err := open(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if initial, ok, err := incremental.Stream(p, recv, cancel, incremental.Limits{MaxItems: opts.MaxItems, Timeout: opts.Timeout}); ok {
+		if err != nil {
+			return nil, err
+		}
+		return initial, nil
+	}
+	defer cancel()
+	items, err := collectStream(recv, opts.MaxItems)
+	if err != nil {
+		if items, err = partialList(p, items, err); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// partialList applies the stream policy to a broken list. Under
+// StreamPartial the error is reported through the partialErrors extension so
+// that the field keeps its data.
+func partialList[T any](p graphql.ResolveParams, items []T, err error) ([]T, error) {
+	if streamOptions(p.Context).Policy != StreamPartial {
+		return nil, err
+	}
+	errs, ok := p.Context.Value(partialErrorsKey{}).(*partialErrors)
+	if !ok {
+		return nil, err
+	}
+	located := gqlerrors.NewLocatedError(err, gqlerrors.FieldASTsToNodeASTs(p.Info.FieldASTs))
+	located.Path = p.Info.Path.AsArray()
+	errs.add(located)
+	return items, nil
+}
+
+type partialErrorsKey struct{}
+
+type partialErrors struct {
+	mu   sync.Mutex
+	errs []*gqlerrors.Error
+}
+
+func (e *partialErrors) add(err *gqlerrors.Error) {
+	e.mu.Lock()
+	e.errs = append(e.errs, err)
+	e.mu.Unlock()
+}
+
+// partialErrorsExtension appends the errors of partially resolved lists to the
+// result, graphql-go drops the value of a field whose resolver errors.
+type partialErrorsExtension struct{}
+
+func (partialErrorsExtension) Init(ctx context.Context, p *graphql.Params) context.Context {
+	return ctx
+}
+
+func (partialErrorsExtension) Name() string {
+	return "partialErrors"
+}
+
+func (partialErrorsExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (partialErrorsExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+// ExecutionDidStart sets up the collector rather than Init, the incremental
+// handler calls graphql.Execute directly.
+func (partialErrorsExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	errs := &partialErrors{}
+	return context.WithValue(ctx, partialErrorsKey{}, errs), func(result *graphql.Result) {
+		errs.mu.Lock()
+		defer errs.mu.Unlock()
+		for _, err := range errs.errs {
+			result.Errors = append(result.Errors, gqlerrors.FormatError(err))
+		}
+	}
+}
+
+func (partialErrorsExtension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	return ctx, func(interface{}, error) {}
+}
+
+func (partialErrorsExtension) HasResult() bool {
+	return false
+}
+
+func (partialErrorsExtension) GetResult(context.Context) interface{} {
+	return nil
+}
@@ -0,0 +1,212 @@
+package graph
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/Nishad4140/api_gateway/events"
+	"github.com/Nishad4140/api_gateway/middleware"
+	"github.com/Nishad4140/proto_files/pb"
+	"github.com/graphql-go/graphql"
+)
+
+const subscriptionBuffer = 16
+
+var EventBus = events.NewBus()
+
+func InitEventBus(bus *events.Bus) {
+	EventBus = bus
+}
+
+func publishOrderEvent(eventType string, orderId, statusId, userId uint32) {
+	event := events.OrderEvent{
+		Type:       eventType,
+		OrderId:    orderId,
+		StatusId:   statusId,
+		UserId:     userId,
+		OccurredAt: time.Now(),
+	}
+	EventBus.Publish(events.TopicOrder, event)
+	// the bus drops events for slow subscribers, webhook deliveries are
+	// queued directly so that none get lost
+	if Webhooks != nil {
+		Webhooks.Enqueue("ORDER_"+eventType, event)
+	}
+}
+
+var OrderEventType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "orderEvent",
+		Fields: graphql.Fields{
+			"type": &graphql.Field{
+				Type: graphql.String,
+			},
+			"orderId": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"orderStatusId": &graphql.Field{
+				Type:              graphql.Int,
+				DeprecationReason: "Use orderStatus.",
+			},
+			"orderStatus": &graphql.Field{
+				Type: OrderStatusEnum,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					event := p.Source.(events.OrderEvent)
+					if name, ok := nameForID(OrderStatusIDs, event.StatusId); ok {
+						return name, nil
+					}
+					return nil, nil
+				},
+			},
+			"occurredAt": &graphql.Field{
+				Type: graphql.DateTime,
+			},
+			"order": &graphql.Field{
+				Type: OrderType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					event := p.Source.(events.OrderEvent)
+					return OrderConn.GetOrder(p.Context, &pb.OrderId{
+						OrderId: event.OrderId,
+					})
+				},
+			},
+		},
+	},
+)
+
+var Subscription = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"orderStatusChanged": &graphql.Field{
+				Type: OrderEventType,
+				Args: graphql.FieldConfigArgument{
+					"orderId": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.Int),
+					},
+				},
+				Subscribe: middleware.UserMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					orderId := uint32(p.Args["orderId"].(int))
+					if isAdmin, _ := p.Context.Value("isAdmin").(bool); !isAdmin {
+						owned, err := userOrderIDs(p.Context, uint32(p.Context.Value("userId").(uint)))
+						if err != nil {
+							return nil, err
+						}
+						if !owned[orderId] {
+							return nil, apperror.New(apperror.CodeNotFound, "order not found")
+						}
+					}
+					return subscribeOrderEvents(p.Context, func(event events.OrderEvent) bool {
+						return event.OrderId == orderId
+					}), nil
+				}),
+				Resolve: resolveEvent,
+			},
+			"myOrders": &graphql.Field{
+				Type: OrderEventType,
+				Subscribe: middleware.UserMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					userId := uint32(p.Context.Value("userId").(uint))
+					owned, err := userOrderIDs(p.Context, userId)
+					if err != nil {
+						return nil, err
+					}
+					// orders placed after subscribing are matched by their
+					// recorded owner, never by the user id of the event
+					return subscribeOrderEvents(p.Context, func(event events.OrderEvent) bool {
+						return owned[event.OrderId] || orderOwner(event.OrderId) == userId
+					}), nil
+				}),
+				Resolve: resolveEvent,
+			},
+			"lowStock": &graphql.Field{
+				Type: LowStockEventType,
+				Subscribe: middleware.AdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					return subscribeTopic(p.Context, events.TopicLowStock, nil), nil
+				}),
+				Resolve: resolveEvent,
+			},
+		},
+	},
+)
+
+func resolveEvent(p graphql.ResolveParams) (interface{}, error) {
+	return p.Source, nil
+}
+
+// subscribeTopic forwards the events published on topic that are accepted by
+// filter until ctx is done.
+func subscribeTopic(ctx context.Context, topic string, filter func(interface{}) bool) chan interface{} {
+	ch, unsubscribe := EventBus.Subscribe(topic, subscriptionBuffer)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-ch:
+				if !ok {
+					return
+				}
+				if filter != nil && !filter(event) {
+					continue
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+func subscribeOrderEvents(ctx context.Context, filter func(events.OrderEvent) bool) chan interface{} {
+	return subscribeTopic(ctx, events.TopicOrder, func(payload interface{}) bool {
+		event, ok := payload.(events.OrderEvent)
+		return ok && filter(event)
+	})
+}
+
+// orderOwners maps the orders seen by the gateway to the user who placed
+// them, the order service does not return the owner of an order.
+var orderOwners sync.Map
+
+func rememberOrderOwner(orderId, userId uint32) {
+	orderOwners.Store(orderId, userId)
+}
+
+// orderOwner returns the user who placed orderId, 0 when the gateway has not
+// seen the order.
+func orderOwner(orderId uint32) uint32 {
+	owner, _ := orderOwners.Load(orderId)
+	userId, _ := owner.(uint32)
+	return userId
+}
+
+// userOrderIDs returns the ids of every order placed by the user.
+func userOrderIDs(ctx context.Context, userId uint32) (map[uint32]bool, error) {
+	orders, err := OrderConn.GetAllOrdersUser(ctx, &pb.UserId{
+		UserId: userId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[uint32]bool)
+	for {
+		order, err := orders.Recv()
+		if err == io.EOF {
+			return owned, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		owned[order.OrderId] = true
+		rememberOrderOwner(order.OrderId, userId)
+	}
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
 
+	"github.com/Nishad4140/api_gateway/apperror"
 	"github.com/Nishad4140/api_gateway/authorize"
+	"github.com/Nishad4140/api_gateway/events"
+	"github.com/Nishad4140/api_gateway/incremental"
 	"github.com/Nishad4140/api_gateway/middleware"
+	"github.com/Nishad4140/api_gateway/validation"
 	"github.com/Nishad4140/proto_files/pb"
 	"github.com/graphql-go/graphql"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -64,10 +70,8 @@ var UserType = graphql.NewObject(
 				Type: graphql.String,
 			},
 			"email": &graphql.Field{
-				Type: graphql.String,
-			},
-			"password": &graphql.Field{
-				Type: graphql.String,
+				Type:    graphql.String,
+				Resolve: ownerOrAdmin(userOwnerID),
 			},
 		},
 	},
@@ -92,6 +96,40 @@ var CartType = graphql.NewObject(
 			"total": &graphql.Field{
 				Type: graphql.Float,
 			},
+			"product": &graphql.Field{
+				Type:    ProductType,
+				Resolve: resolveCartProduct,
+			},
+			"user": &graphql.Field{
+				Type:    UserType,
+				Resolve: resolveCartUser,
+			},
+		},
+	},
+)
+
+// OrderItemType has no product field, the order service does not say which
+// product an order item is for: its id is the id of the item itself.
+var OrderItemType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "orderItem",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"orderId": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"name": &graphql.Field{
+				Type:              graphql.String,
+				DeprecationReason: "order items do not carry the product name, it is always null",
+			},
+			"price": &graphql.Field{
+				Type: graphql.Float,
+			},
+			"quantity": &graphql.Field{
+				Type: graphql.Int,
+			},
 		},
 	},
 )
@@ -104,16 +142,26 @@ var OrderType = graphql.NewObject(
 				Type: graphql.Int,
 			},
 			"orderItems": &graphql.Field{
-				Type: graphql.NewList(ProductType),
+				Type: graphql.NewList(OrderItemType),
 			},
 			"addressId": &graphql.Field{
 				Type: graphql.Int,
 			},
 			"orderStatusId": &graphql.Field{
-				Type: graphql.Int,
+				Type:              graphql.Int,
+				DeprecationReason: "Use orderStatus.",
+			},
+			"orderStatus": &graphql.Field{
+				Type:    OrderStatusEnum,
+				Resolve: resolveOrderStatus,
 			},
 			"paymentTypeId": &graphql.Field{
-				Type: graphql.Int,
+				Type:              graphql.Int,
+				DeprecationReason: "Use paymentType.",
+			},
+			"paymentType": &graphql.Field{
+				Type:    PaymentTypeEnum,
+				Resolve: resolvePaymentType,
 			},
 			"total": &graphql.Field{
 				Type: graphql.Float,
@@ -122,6 +170,12 @@ var OrderType = graphql.NewObject(
 	},
 )
 
+var (
+	ProductConnectionType = NewConnectionType("product", ProductType)
+	UserConnectionType    = NewConnectionType("user", UserType)
+	OrderConnectionType   = NewConnectionType("order", OrderType)
+)
+
 var RootQuery = graphql.NewObject(
 	graphql.ObjectConfig{
 		Name: "RootQuery",
@@ -155,11 +209,7 @@ var RootQuery = graphql.NewObject(
 
 					fmt.Println("after token")
 
-					http.SetCookie(w, &http.Cookie{
-						Name:  "jwtToken",
-						Value: token,
-						Path:  "/",
-					})
+					middleware.StartSession(p.Context, w, token)
 
 					return res, nil
 				},
@@ -189,11 +239,7 @@ var RootQuery = graphql.NewObject(
 
 					w := p.Context.Value("httpResponseWriter").(http.ResponseWriter)
 
-					http.SetCookie(w, &http.Cookie{
-						Name:  "jwtToken",
-						Value: token,
-						Path:  "/",
-					})
+					middleware.StartSession(p.Context, w, token)
 					return res, nil
 				},
 			},
@@ -222,57 +268,62 @@ var RootQuery = graphql.NewObject(
 
 					w := p.Context.Value("httpResponseWriter").(http.ResponseWriter)
 
-					http.SetCookie(w, &http.Cookie{
-						Name:  "jwtToken",
-						Value: token,
-						Path:  "/",
-					})
+					middleware.StartSession(p.Context, w, token)
 					return res, nil
 				},
 			},
+			"me": &graphql.Field{
+				Type:    MeType,
+				Resolve: middleware.UserMiddleware(resolveMe),
+			},
 			"GetAllAdmins": &graphql.Field{
 				Type: graphql.NewList(UserType),
 				Resolve: middleware.SupAdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
-					admins, err := UsersConn.GetAllAdmins(context.Background(), &emptypb.Empty{})
-					if err != nil {
-						return nil, err
-					}
-					var res []*pb.UserResponse
-					for {
-						admin, err := admins.Recv()
-						if err == io.EOF {
-							break
-						}
-						fmt.Println(admin.Name)
+					return resolveStream(p, func(ctx context.Context) (func() (*pb.UserResponse, error), error) {
+						admins, err := UsersConn.GetAllAdmins(ctx, &emptypb.Empty{})
 						if err != nil {
-							fmt.Println(err.Error())
+							return nil, err
 						}
-						res = append(res, admin)
+						return admins.Recv, nil
+					})
+				}),
+			},
+			"GetAllAdminsConnection": &graphql.Field{
+				Type: UserConnectionType,
+				Args: ConnectionArgs,
+				Resolve: middleware.SupAdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					ctx, cancel := context.WithTimeout(p.Context, streamOptions(p.Context).Timeout)
+					defer cancel()
+					admins, err := UsersConn.GetAllAdmins(ctx, &emptypb.Empty{})
+					if err != nil {
+						return nil, err
 					}
-					fmt.Println(res)
-					return res, nil
+					return NewConnection(p, admins.Recv)
 				}),
 			},
 			"GetAllUsers": &graphql.Field{
 				Type: graphql.NewList(UserType),
 				Resolve: middleware.AdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
-					users, err := UsersConn.GetAllUsers(context.Background(), &emptypb.Empty{})
-					if err != nil {
-						return nil, err
-					}
-					var res []*pb.UserResponse
-					for {
-						user, err := users.Recv()
-						if err == io.EOF {
-							break
-						}
+					return resolveStream(p, func(ctx context.Context) (func() (*pb.UserResponse, error), error) {
+						users, err := UsersConn.GetAllUsers(ctx, &emptypb.Empty{})
 						if err != nil {
-							fmt.Println(err.Error())
+							return nil, err
 						}
-						res = append(res, user)
-
+						return users.Recv, nil
+					})
+				}),
+			},
+			"GetAllUsersConnection": &graphql.Field{
+				Type: UserConnectionType,
+				Args: ConnectionArgs,
+				Resolve: middleware.AdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					ctx, cancel := context.WithTimeout(p.Context, streamOptions(p.Context).Timeout)
+					defer cancel()
+					users, err := UsersConn.GetAllUsers(ctx, &emptypb.Empty{})
+					if err != nil {
+						return nil, err
 					}
-					return res, nil
+					return NewConnection(p, users.Recv)
 				}),
 			},
 			"product": &graphql.Field{
@@ -283,104 +334,159 @@ var RootQuery = graphql.NewObject(
 					},
 				},
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					return ProductsConn.GetProduct(context.Background(), &pb.GetProductByID{
-						Id: uint32(p.Args["id"].(int)),
-					})
+					return cachedProduct(context.Background(), uint32(p.Args["id"].(int)))
 				},
 			},
 			"products": &graphql.Field{
 				Type: graphql.NewList(ProductType),
+				Args: ProductListArgs,
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					filter, err := parseProductFilter(p.Args)
+					if err != nil {
+						return nil, err
+					}
 
 					var res []*pb.AddProductResponse
 
-					products, err := ProductsConn.GetAllProducts(context.Background(), &emptypb.Empty{})
+					products, err := allProducts(p.Context)
 					if err != nil {
-						fmt.Println(err.Error())
+						if products, err = partialList(p, products, err); err != nil {
+							return nil, err
+						}
 					}
 
+					recv := productRecv(sliceRecv(products), filter, parseProductSort(p.Args), 0)
+
 					for {
-						prod, err := products.Recv()
+						prod, err := recv()
 						if err == io.EOF {
 							break
 						}
 						if err != nil {
 							fmt.Println(err)
+							return nil, err
 						}
 						res = append(res, prod)
 					}
-					return res, err
+					return res, nil
 				},
 			},
-			"GetAllCartItems": &graphql.Field{
-				Type: graphql.NewList(CartType),
-				Resolve: middleware.UserMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
-					userId := p.Context.Value("userId").(uint)
-					cartItems, err := CartConn.GetAllCart(context.Background(), &pb.CartCreate{
-						UserId: uint32(userId),
-					})
+			"productsConnection": &graphql.Field{
+				Type: ProductConnectionType,
+				Args: MergeArgs(ConnectionArgs, ProductListArgs),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					filter, err := parseProductFilter(p.Args)
 					if err != nil {
 						return nil, err
 					}
-					var res []*pb.GetAllCartResponse
-					for {
-						item, err := cartItems.Recv()
-						if err == io.EOF {
-							break
-						}
+					// serve from the cached catalog when there is one, otherwise
+					// stream only as far as the page needs
+					var productsRecv func() (*pb.AddProductResponse, error)
+					if cached, ok := cachedProducts(); ok {
+						productsRecv = sliceRecv(cached)
+					} else {
+						ctx, cancel := context.WithTimeout(p.Context, streamOptions(p.Context).Timeout)
+						defer cancel()
+						products, err := ProductsConn.GetAllProducts(ctx, &emptypb.Empty{})
 						if err != nil {
-							fmt.Println(err.Error())
+							return nil, err
 						}
-						res = append(res, item)
+						productsRecv = products.Recv
 					}
-					return res, nil
+					recv := productRecv(productsRecv, filter, parseProductSort(p.Args), streamOptions(p.Context).MaxItems)
+					return NewConnection(p, recv)
+				},
+			},
+			"GetAllCartItems": &graphql.Field{
+				Type: graphql.NewList(CartType),
+				Resolve: middleware.UserMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					userId := p.Context.Value("userId").(uint)
+					return resolveStream(p, func(ctx context.Context) (func() (*pb.GetAllCartResponse, error), error) {
+						cartItems, err := CartConn.GetAllCart(ctx, &pb.CartCreate{
+							UserId: uint32(userId),
+						})
+						if err != nil {
+							return nil, err
+						}
+						return cartItems.Recv, nil
+					})
 				}),
 			},
+			"cartSummary": &graphql.Field{
+				Type:    CartSummaryType,
+				Resolve: middleware.UserMiddleware(resolveCartSummary),
+			},
 			"GetAllOrdersUser": &graphql.Field{
 				Type: graphql.NewList(OrderType),
 				Resolve: middleware.UserMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
 					userIdVal := p.Context.Value("userId").(uint)
-					orders, err := OrderConn.GetAllOrdersUser(context.Background(), &pb.UserId{
-						UserId: uint32(userIdVal),
-					})
-					if err != nil {
-						return nil, err
-					}
-					var AllOrders []*pb.GetAllOrdersResponse
-					for {
-						order, err := orders.Recv()
-						if err == io.EOF {
-							break
-						}
+					return resolveStream(p, func(ctx context.Context) (func() (*pb.GetAllOrdersResponse, error), error) {
+						orders, err := OrderConn.GetAllOrdersUser(ctx, &pb.UserId{
+							UserId: uint32(userIdVal),
+						})
 						if err != nil {
 							return nil, err
 						}
-						AllOrders = append(AllOrders, order)
-					}
-					fmt.Println(AllOrders)
-					return AllOrders, nil
+						return orders.Recv, nil
+					})
 				}),
 			},
 			"GetAllOrders": &graphql.Field{
 				Type: graphql.NewList(OrderType),
 				Resolve: middleware.AdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
-					orders, err := OrderConn.GetAllOrders(context.Background(), &pb.NoParam{})
-					if err != nil {
-						return nil, err
-					}
-					var res []*pb.GetAllOrdersResponse
-					for {
-						order, err := orders.Recv()
-						if err == io.EOF {
-							break
-						}
+					return resolveStream(p, func(ctx context.Context) (func() (*pb.GetAllOrdersResponse, error), error) {
+						orders, err := OrderConn.GetAllOrders(ctx, &pb.NoParam{})
 						if err != nil {
 							return nil, err
 						}
-						res = append(res, order)
+						return orders.Recv, nil
+					})
+				}),
+			},
+			"GetAllOrdersConnection": &graphql.Field{
+				Type: OrderConnectionType,
+				Args: ConnectionArgs,
+				Resolve: middleware.AdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					ctx, cancel := context.WithTimeout(p.Context, streamOptions(p.Context).Timeout)
+					defer cancel()
+					orders, err := OrderConn.GetAllOrders(ctx, &pb.NoParam{})
+					if err != nil {
+						return nil, err
 					}
-
-					return res, nil
+					return NewConnection(p, orders.Recv)
+				}),
+			},
+			"webhooks": &graphql.Field{
+				Type: graphql.NewList(WebhookEndpointType),
+				Resolve: middleware.SupAdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					if err := webhooksEnabled(); err != nil {
+						return nil, err
+					}
+					return Webhooks.Endpoints(), nil
+				}),
+			},
+			"webhookDeliveries": &graphql.Field{
+				Type: graphql.NewList(WebhookDeliveryType),
+				Args: graphql.FieldConfigArgument{
+					"endpointId": &graphql.ArgumentConfig{
+						Type: graphql.String,
+					},
+					"status": &graphql.ArgumentConfig{
+						Type: WebhookDeliveryStatusEnum,
+					},
+					"limit": &graphql.ArgumentConfig{
+						Type:         graphql.Int,
+						DefaultValue: 50,
+					},
+				},
+				Resolve: middleware.SupAdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					if err := webhooksEnabled(); err != nil {
+						return nil, err
+					}
+					endpointId, _ := p.Args["endpointId"].(string)
+					status, _ := p.Args["status"].(string)
+					limit, _ := p.Args["limit"].(int)
+					return Webhooks.Deliveries(endpointId, status, limit), nil
 				}),
 			},
 			"GetOrder": &graphql.Field{
@@ -404,17 +510,28 @@ var Mutation = graphql.NewObject(
 	graphql.ObjectConfig{
 		Name: "Mutation",
 		Fields: graphql.Fields{
+			"logout": &graphql.Field{
+				Type: graphql.Boolean,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					w := p.Context.Value("httpResponseWriter").(http.ResponseWriter)
+					middleware.EndSession(p.Context, w)
+					return true, nil
+				},
+			},
 			"UserSignUp": &graphql.Field{
 				Type: UserType,
 				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{
+						Type: SignUpInput,
+					},
 					"name": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.String),
+						Type: graphql.String,
 					},
 					"email": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.String),
+						Type: graphql.String,
 					},
 					"password": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.String),
+						Type: graphql.String,
 					},
 				},
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
@@ -428,17 +545,14 @@ var Mutation = graphql.NewObject(
 					// 	return nil, err
 					// }
 					// return userData, nil
-					name, _ := p.Args["name"].(string)
-					email, _ := p.Args["email"].(string)
-					password, _ := p.Args["password"].(string)
-
-					if name == "" || email == "" || password == "" {
-						return nil, fmt.Errorf("name, email, and password are required")
+					args := inputArgs(p.Args)
+					if err := validation.Check(signUpRules, args); err != nil {
+						return nil, err
 					}
 					res, err := UsersConn.UserSignUp(context.Background(), &pb.UserSignUpRequest{
-						Name:     p.Args["name"].(string),
-						Email:    p.Args["email"].(string),
-						Password: p.Args["password"].(string),
+						Name:     args["name"].(string),
+						Email:    args["email"].(string),
+						Password: args["password"].(string),
 					})
 					if err != nil {
 						return nil, err
@@ -465,22 +579,28 @@ var Mutation = graphql.NewObject(
 			"addAdmin": &graphql.Field{
 				Type: UserType,
 				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{
+						Type: SignUpInput,
+					},
 					"name": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.String),
+						Type: graphql.String,
 					},
 					"email": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.String),
+						Type: graphql.String,
 					},
 					"password": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.String),
+						Type: graphql.String,
 					},
 				},
 				Resolve: middleware.SupAdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
-
+					args := inputArgs(p.Args)
+					if err := validation.Check(signUpRules, args); err != nil {
+						return nil, err
+					}
 					admin, err := UsersConn.AddAdmin(context.Background(), &pb.UserSignUpRequest{
-						Name:     p.Args["name"].(string),
-						Email:    p.Args["email"].(string),
-						Password: p.Args["password"].(string),
+						Name:     args["name"].(string),
+						Email:    args["email"].(string),
+						Password: args["password"].(string),
 					})
 					fmt.Println(admin)
 					if err != nil {
@@ -494,28 +614,37 @@ var Mutation = graphql.NewObject(
 			"AddProduct": &graphql.Field{
 				Type: ProductType,
 				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{
+						Type: ProductInput,
+					},
 					"name": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.String),
+						Type: graphql.String,
 					},
 					"price": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.Int),
+						Type: graphql.Int,
 					},
 					"quantity": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.Int),
+						Type: graphql.Int,
 					},
 				},
 				Resolve: middleware.AdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
-
-					fmt.Println("here reached...")
+					args := inputArgs(p.Args)
+					if err := validation.Check(productRules, args); err != nil {
+						return nil, err
+					}
 					products, err := ProductsConn.AddProduct(context.Background(), &pb.AddProductRequest{
-						Name:     p.Args["name"].(string),
-						Price:    int32(p.Args["price"].(int)),
-						Quantity: int32(p.Args["quantity"].(int)),
+						Name:     args["name"].(string),
+						Price:    int32(args["price"].(int)),
+						Quantity: int32(args["quantity"].(int)),
 					})
 					if err != nil {
 						fmt.Println(err.Error())
 						return nil, err
 					}
+					invalidateProducts(products.Id)
+					// a new product is reported when it starts out below its
+					// threshold
+					checkLowStock(p.Context, products, math.MaxInt32)
 					return products, nil
 				}),
 			},
@@ -533,30 +662,57 @@ var Mutation = graphql.NewObject(
 					},
 				},
 				Resolve: middleware.AdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
-					id, _ := strconv.Atoi(p.Args["id"].(string))
-					return ProductsConn.UpdateStock(context.Background(), &pb.UpdateStockRequest{
+					if err := validation.Check(stockRules, p.Args); err != nil {
+						return nil, err
+					}
+					id, err := strconv.Atoi(p.Args["id"].(string))
+					if err != nil || id < 1 {
+						return nil, &apperror.Error{
+							Code:    apperror.CodeBadUserInput,
+							Message: "invalid input",
+							Fields:  []apperror.FieldError{{Field: "id", Message: "must be a product id"}},
+						}
+					}
+					product, err := ProductsConn.UpdateStock(context.Background(), &pb.UpdateStockRequest{
 						Id:       uint32(id),
 						Quantity: int32(p.Args["stock"].(int)),
 						Increase: p.Args["increase"].(bool),
 					})
+					if err != nil {
+						return nil, err
+					}
+					invalidateProducts(product.Id)
+					previous := product.Quantity + int32(p.Args["stock"].(int))
+					if p.Args["increase"].(bool) {
+						previous = product.Quantity - int32(p.Args["stock"].(int))
+					}
+					checkLowStock(p.Context, product, previous)
+					return product, nil
 				}),
 			},
 			"AddToCart": &graphql.Field{
 				Type: CartType,
 				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{
+						Type: CartItemInput,
+					},
 					"productId": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.Int),
+						Type: graphql.Int,
 					},
 					"quantity": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.Int),
+						Type: graphql.Int,
 					},
 				},
 				Resolve: middleware.UserMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					args := inputArgs(p.Args)
+					if err := validation.Check(cartItemRules, args); err != nil {
+						return nil, err
+					}
 					userIDval := p.Context.Value("userId").(uint)
 					res, err := CartConn.AddToCart(context.Background(), &pb.AddToCartRequest{
 						UserId:   uint32(userIDval),
-						ProdId:   uint32(p.Args["productId"].(int)),
-						Quantity: int32(p.Args["quantity"].(int)),
+						ProdId:   uint32(args["productId"].(int)),
+						Quantity: int32(args["quantity"].(int)),
 					})
 					if err != nil {
 						return nil, err
@@ -584,12 +740,26 @@ var Mutation = graphql.NewObject(
 				Type: OrderType,
 				Resolve: middleware.UserMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
 					userId := p.Context.Value("userId").(uint)
+					if err := checkCartStock(p.Context, uint32(userId)); err != nil {
+						return nil, err
+					}
+					// the order does not say which products it holds, the cart
+					// does until the order is placed
+					ordered, err := cartQuantities(p.Context, uint32(userId))
+					if err != nil {
+						log.Println("low stock check:", err.Error())
+					}
 					order, err := OrderConn.OrderAll(context.Background(), &pb.UserId{
 						UserId: uint32(userId),
 					})
 					if err != nil {
 						return nil, err
 					}
+					rememberOrderOwner(order.OrderId, uint32(userId))
+					publishOrderEvent(events.OrderPlaced, order.OrderId, 0, uint32(userId))
+					// placing an order changes stock, the cached catalog is stale
+					invalidateProducts()
+					go checkOrderStock(ordered)
 
 					return order, nil
 				}),
@@ -602,9 +772,28 @@ var Mutation = graphql.NewObject(
 					},
 				},
 				Resolve: middleware.UserMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
-					return OrderConn.CancelOrder(context.Background(), &pb.OrderId{
-						OrderId: uint32(p.Args["orderId"].(int)),
+					userId := uint32(p.Context.Value("userId").(uint))
+					orderId := uint32(p.Args["orderId"].(int))
+					if isAdmin, _ := p.Context.Value("isAdmin").(bool); !isAdmin {
+						owned, err := userOrderIDs(p.Context, userId)
+						if err != nil {
+							return nil, err
+						}
+						if !owned[orderId] {
+							return nil, apperror.New(apperror.CodeNotFound, "order not found")
+						}
+					}
+					if err := checkOrderTransition(context.Background(), orderId, StatusCancelled); err != nil {
+						return nil, err
+					}
+					order, err := OrderConn.CancelOrder(context.Background(), &pb.OrderId{
+						OrderId: orderId,
 					})
+					if err != nil {
+						return nil, err
+					}
+					publishOrderEvent(events.OrderCancelled, order.OrderId, OrderStatusIDs[StatusCancelled], orderOwner(order.OrderId))
+					return order, nil
 				}),
 			},
 			"ChangeOrderStatus": &graphql.Field{
@@ -614,14 +803,98 @@ var Mutation = graphql.NewObject(
 						Type: graphql.NewNonNull(graphql.Int),
 					},
 					"statusId": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.Int),
+						Type:        graphql.Int,
+						Description: "Deprecated, use status.",
+					},
+					"status": &graphql.ArgumentConfig{
+						Type: OrderStatusEnum,
 					},
 				},
 				Resolve: middleware.AdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
-					return OrderConn.ChangeOrderStatus(context.Background(), &pb.ChangeStatusRequest{
-						OrderId:  uint32(p.Args["orderId"].(int)),
-						StatusId: uint32(p.Args["statusId"].(int)),
+					orderId := uint32(p.Args["orderId"].(int))
+					statusId, status, err := orderStatusArg(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					if err := checkOrderTransition(context.Background(), orderId, status); err != nil {
+						return nil, err
+					}
+					order, err := OrderConn.ChangeOrderStatus(context.Background(), &pb.ChangeStatusRequest{
+						OrderId:  orderId,
+						StatusId: statusId,
 					})
+					if err != nil {
+						return nil, err
+					}
+					publishOrderEvent(events.OrderStatusChanged, order.OrderId, statusId, orderOwner(order.OrderId))
+					return order, nil
+				}),
+			},
+			"registerWebhook": &graphql.Field{
+				Type: WebhookRegistrationType,
+				Args: graphql.FieldConfigArgument{
+					"url": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"events": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(WebhookEventEnum))),
+					},
+					"secret": &graphql.ArgumentConfig{
+						Type: graphql.String,
+					},
+				},
+				Resolve: middleware.SupAdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					if err := webhooksEnabled(); err != nil {
+						return nil, err
+					}
+					var eventNames []string
+					for _, event := range p.Args["events"].([]interface{}) {
+						eventNames = append(eventNames, event.(string))
+					}
+					secret, _ := p.Args["secret"].(string)
+					endpoint, err := Webhooks.Register(p.Args["url"].(string), secret, eventNames)
+					if err != nil {
+						return nil, apperror.New(apperror.CodeBadUserInput, err.Error())
+					}
+					return map[string]interface{}{
+						"endpoint": endpoint,
+						"secret":   endpoint.Secret,
+					}, nil
+				}),
+			},
+			"deleteWebhook": &graphql.Field{
+				Type: graphql.Boolean,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: middleware.SupAdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					if err := webhooksEnabled(); err != nil {
+						return nil, err
+					}
+					if !Webhooks.Remove(p.Args["id"].(string)) {
+						return nil, apperror.New(apperror.CodeNotFound, "webhook not found")
+					}
+					return true, nil
+				}),
+			},
+			"retryWebhookDelivery": &graphql.Field{
+				Type: WebhookDeliveryType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: middleware.SupAdminMiddleware(func(p graphql.ResolveParams) (interface{}, error) {
+					if err := webhooksEnabled(); err != nil {
+						return nil, err
+					}
+					delivery, err := Webhooks.Retry(p.Args["id"].(string))
+					if err != nil {
+						return nil, apperror.New(apperror.CodeBadUserInput, err.Error())
+					}
+					return *delivery, nil
 				}),
 			},
 		},
@@ -629,6 +902,9 @@ var Mutation = graphql.NewObject(
 )
 
 var Schema, _ = graphql.NewSchema(graphql.SchemaConfig{
-	Query:    RootQuery,
-	Mutation: Mutation,
+	Query:        RootQuery,
+	Mutation:     Mutation,
+	Subscription: Subscription,
+	Directives:   incremental.Directives(),
+	Extensions:   []graphql.Extension{partialErrorsExtension{}, warningsExtension{}, incremental.Extension{}},
 })
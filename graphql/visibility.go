@@ -0,0 +1,82 @@
+package graph
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Nishad4140/api_gateway/middleware"
+	"github.com/graphql-go/graphql"
+)
+
+// secretFieldPattern matches field names that must never be part of an output
+// type, such as password or token.
+var secretFieldPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|hash|salt|apikey|privatekey)`)
+
+// exposedSecretFields are output fields that match secretFieldPattern on
+// purpose, as "type.field".
+var exposedSecretFields = map[string]bool{
+	"webhookRegistration.secret": true,
+}
+
+// ownerOrAdmin resolves a field only for admins and for the user the source
+// belongs to, everyone else gets null.
+func ownerOrAdmin(ownerID func(source interface{}) (uint32, bool)) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		principal := middleware.PrincipalFrom(p.Context)
+		if !principal.Authenticated() {
+			return nil, nil
+		}
+		if !principal.IsAdmin {
+			owner, ok := ownerID(p.Source)
+			if !ok || owner != uint32(principal.UserId) {
+				return nil, nil
+			}
+		}
+		return graphql.DefaultResolveFn(p)
+	}
+}
+
+type idRef interface {
+	GetId() uint32
+}
+
+func userOwnerID(source interface{}) (uint32, bool) {
+	user, ok := source.(idRef)
+	if !ok {
+		return 0, false
+	}
+	return user.GetId(), true
+}
+
+// CheckSchema fails when an output type exposes a field named like a secret.
+// It runs at startup so a leaking field never gets served.
+func CheckSchema(schema *graphql.Schema) error {
+	var leaks []string
+	for name, typ := range schema.TypeMap() {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		var fields graphql.FieldDefinitionMap
+		switch typ := typ.(type) {
+		case *graphql.Object:
+			fields = typ.Fields()
+		case *graphql.Interface:
+			fields = typ.Fields()
+		default:
+			continue
+		}
+		for fieldName := range fields {
+			qualified := name + "." + fieldName
+			if secretFieldPattern.MatchString(fieldName) && !exposedSecretFields[qualified] {
+				leaks = append(leaks, qualified)
+			}
+		}
+	}
+	if len(leaks) > 0 {
+		sort.Strings(leaks)
+		return fmt.Errorf("schema exposes secret fields: %s", strings.Join(leaks, ", "))
+	}
+	return nil
+}
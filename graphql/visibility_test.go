@@ -0,0 +1,46 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestSchemaExposesNoSecretFields(t *testing.T) {
+	if err := CheckSchema(&Schema); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckSchemaRejectsSecretFields(t *testing.T) {
+	leaky := graphql.NewObject(graphql.ObjectConfig{
+		Name: "leakyUser",
+		Fields: graphql.Fields{
+			"id":       &graphql.Field{Type: graphql.Int},
+			"password": &graphql.Field{Type: graphql.String},
+			"token":    &graphql.Field{Type: graphql.String},
+		},
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"user": &graphql.Field{Type: leaky},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = CheckSchema(&schema)
+	if err == nil {
+		t.Fatal("expected the password and token fields to be reported")
+	}
+	for _, field := range []string{"leakyUser.password", "leakyUser.token"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("%s is not reported: %s", field, err.Error())
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+type warningsKey struct{}
+
+type warnings struct {
+	mu    sync.Mutex
+	items []interface{}
+}
+
+// addWarning reports a problem that did not fail the operation, it is sent
+// under extensions.warnings of the response.
+func addWarning(ctx context.Context, warning interface{}) {
+	w, ok := ctx.Value(warningsKey{}).(*warnings)
+	if !ok {
+		return
+	}
+	w.mu.Lock()
+	w.items = append(w.items, warning)
+	w.mu.Unlock()
+}
+
+// warningsExtension collects the warnings of an execution and adds them to
+// the result.
+type warningsExtension struct{}
+
+func (warningsExtension) Init(ctx context.Context, p *graphql.Params) context.Context {
+	return ctx
+}
+
+func (warningsExtension) Name() string {
+	return "warnings"
+}
+
+func (warningsExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (warningsExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (warningsExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	w := &warnings{}
+	return context.WithValue(ctx, warningsKey{}, w), func(result *graphql.Result) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if len(w.items) == 0 {
+			return
+		}
+		if result.Extensions == nil {
+			result.Extensions = map[string]interface{}{}
+		}
+		result.Extensions["warnings"] = w.items
+	}
+}
+
+func (warningsExtension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	return ctx, func(interface{}, error) {}
+}
+
+func (warningsExtension) HasResult() bool {
+	return false
+}
+
+func (warningsExtension) GetResult(context.Context) interface{} {
+	return nil
+}
@@ -0,0 +1,131 @@
+package graph
+
+import (
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/Nishad4140/api_gateway/webhook"
+	"github.com/graphql-go/graphql"
+)
+
+var Webhooks *webhook.Dispatcher
+
+func InitWebhooks(dispatcher *webhook.Dispatcher) {
+	Webhooks = dispatcher
+}
+
+func webhooksEnabled() error {
+	if Webhooks == nil {
+		return apperror.New(apperror.CodeFailedPrecondition, "webhooks are not configured")
+	}
+	return nil
+}
+
+var WebhookEventEnum = graphql.NewEnum(
+	graphql.EnumConfig{
+		Name: "WebhookEvent",
+		Values: graphql.EnumValueConfigMap{
+			"ORDER_PLACED": &graphql.EnumValueConfig{
+				Value: "ORDER_PLACED",
+			},
+			"ORDER_CANCELLED": &graphql.EnumValueConfig{
+				Value: "ORDER_CANCELLED",
+			},
+			"ORDER_STATUS_CHANGED": &graphql.EnumValueConfig{
+				Value: "ORDER_STATUS_CHANGED",
+			},
+		},
+	},
+)
+
+var WebhookDeliveryStatusEnum = graphql.NewEnum(
+	graphql.EnumConfig{
+		Name: "WebhookDeliveryStatus",
+		Values: graphql.EnumValueConfigMap{
+			webhook.StatusPending: &graphql.EnumValueConfig{
+				Value: webhook.StatusPending,
+			},
+			webhook.StatusDelivered: &graphql.EnumValueConfig{
+				Value: webhook.StatusDelivered,
+			},
+			webhook.StatusDead: &graphql.EnumValueConfig{
+				Value: webhook.StatusDead,
+			},
+		},
+	},
+)
+
+var WebhookEndpointType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "webhookEndpoint",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.String,
+			},
+			"url": &graphql.Field{
+				Type: graphql.String,
+			},
+			"events": &graphql.Field{
+				Type: graphql.NewList(WebhookEventEnum),
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.DateTime,
+			},
+		},
+	},
+)
+
+// WebhookRegistrationType is only returned when registering, it is the one
+// time the signing secret is shown.
+var WebhookRegistrationType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "webhookRegistration",
+		Fields: graphql.Fields{
+			"endpoint": &graphql.Field{
+				Type: WebhookEndpointType,
+			},
+			"secret": &graphql.Field{
+				Type: graphql.String,
+			},
+		},
+	},
+)
+
+var WebhookDeliveryType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "webhookDelivery",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.String,
+			},
+			"endpointId": &graphql.Field{
+				Type: graphql.String,
+			},
+			"event": &graphql.Field{
+				Type: WebhookEventEnum,
+			},
+			"status": &graphql.Field{
+				Type: WebhookDeliveryStatusEnum,
+			},
+			"attempts": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"lastError": &graphql.Field{
+				Type: graphql.String,
+			},
+			"payload": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return string(p.Source.(webhook.Delivery).Payload), nil
+				},
+			},
+			"nextAttemptAt": &graphql.Field{
+				Type: graphql.DateTime,
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.DateTime,
+			},
+			"updatedAt": &graphql.Field{
+				Type: graphql.DateTime,
+			},
+		},
+	},
+)
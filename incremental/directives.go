@@ -0,0 +1,106 @@
+package incremental
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+var DeferDirective = graphql.NewDirective(graphql.DirectiveConfig{
+	Name:        "defer",
+	Description: "Delivers the fragment in a later payload of a multipart/mixed response.",
+	Locations: []string{
+		graphql.DirectiveLocationFragmentSpread,
+		graphql.DirectiveLocationInlineFragment,
+	},
+	Args: graphql.FieldConfigArgument{
+		"if": &graphql.ArgumentConfig{
+			Type:         graphql.Boolean,
+			DefaultValue: true,
+		},
+		"label": &graphql.ArgumentConfig{
+			Type: graphql.String,
+		},
+	},
+})
+
+var StreamDirective = graphql.NewDirective(graphql.DirectiveConfig{
+	Name:        "stream",
+	Description: "Delivers the list items after initialCount in later payloads of a multipart/mixed response.",
+	Locations: []string{
+		graphql.DirectiveLocationField,
+	},
+	Args: graphql.FieldConfigArgument{
+		"if": &graphql.ArgumentConfig{
+			Type:         graphql.Boolean,
+			DefaultValue: true,
+		},
+		"label": &graphql.ArgumentConfig{
+			Type: graphql.String,
+		},
+		"initialCount": &graphql.ArgumentConfig{
+			Type:         graphql.Int,
+			DefaultValue: 0,
+		},
+	},
+})
+
+// Directives returns the specified directives plus @defer and @stream, for
+// the schema config.
+func Directives() []*graphql.Directive {
+	directives := append([]*graphql.Directive{}, graphql.SpecifiedDirectives...)
+	return append(directives, DeferDirective, StreamDirective)
+}
+
+type directiveArgs struct {
+	label        string
+	initialCount int
+}
+
+// activeDirective returns the arguments of the named directive when it is
+// present and its if argument is not false.
+func activeDirective(directives []*ast.Directive, name string, variables map[string]interface{}) (directiveArgs, bool) {
+	for _, directive := range directives {
+		if directive.Name == nil || directive.Name.Value != name {
+			continue
+		}
+		args := directiveArgs{}
+		enabled := true
+		for _, arg := range directive.Arguments {
+			value := argValue(arg.Value, variables)
+			switch arg.Name.Value {
+			case "if":
+				if b, ok := value.(bool); ok {
+					enabled = b
+				}
+			case "label":
+				if s, ok := value.(string); ok {
+					args.label = s
+				}
+			case "initialCount":
+				switch n := value.(type) {
+				case int:
+					args.initialCount = n
+				case float64:
+					args.initialCount = int(n)
+				}
+			}
+		}
+		return args, enabled
+	}
+	return directiveArgs{}, false
+}
+
+func argValue(value ast.Value, variables map[string]interface{}) interface{} {
+	if variable, ok := value.(*ast.Variable); ok {
+		return variables[variable.Name.Value]
+	}
+	switch value := value.(type) {
+	case *ast.IntValue:
+		return graphql.Int.ParseLiteral(value)
+	case *ast.BooleanValue:
+		return value.Value
+	case *ast.StringValue:
+		return value.Value
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package incremental
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/handler"
+)
+
+// ContentType is the response type of incremental delivery, in the format
+// of the 2022-08-24 @defer/@stream proposal.
+const ContentType = `multipart/mixed; boundary="-"; deferSpec=20220824`
+
+const (
+	partBoundary = "\r\n---"
+	endBoundary  = "\r\n-----\r\n"
+)
+
+// Accepts reports whether the client takes a multipart/mixed response.
+func Accepts(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "multipart/mixed")
+}
+
+// Handler answers queries using @defer or @stream with a multipart/mixed
+// response. Every other request is passed on to Next.
+type Handler struct {
+	Schema        *graphql.Schema
+	FormatErrorFn func(err error) gqlerrors.FormattedError
+	Next          http.Handler
+}
+
+func NewHandler(schema *graphql.Schema, formatErrorFn func(err error) gqlerrors.FormattedError, next http.Handler) *Handler {
+	return &Handler{
+		Schema:        schema,
+		FormatErrorFn: formatErrorFn,
+		Next:          next,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || !Accepts(r) {
+		h.Next.ServeHTTP(w, r)
+		return
+	}
+
+	// the request options consume the body, keep it for the next handler
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	opts := handler.NewRequestOptions(r)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	pl, ok := h.plan(opts)
+	if !ok {
+		h.Next.ServeHTTP(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	ex := &executor{schema: h.Schema, plan: pl, variables: opts.Variables, formatErrorFn: h.FormatErrorFn}
+
+	b := &batch{plan: pl}
+	for _, fragment := range pl.root {
+		b.add(&deferRecord{fragment: fragment, path: []interface{}{}, root: true})
+	}
+	result := ex.execute(ctx, b, h.Schema, nil, pl.document)
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(http.StatusOK)
+
+	children := b.take()
+	active := len(children)
+	initial := map[string]interface{}{
+		"data":    result.Data,
+		"hasNext": active > 0,
+	}
+	if len(result.Errors) > 0 {
+		initial["errors"] = ex.formatErrors(result.Errors, nil)
+	}
+	if len(result.Extensions) > 0 {
+		initial["extensions"] = result.Extensions
+	}
+	if err := writePart(w, initial); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	parts := make(chan part)
+	var wg sync.WaitGroup
+	start := func(records []record) {
+		for _, rec := range records {
+			wg.Add(1)
+			go func(rec record) {
+				defer wg.Done()
+				rec.run(ctx, ex, func(p part) bool {
+					select {
+					case parts <- p:
+						return true
+					case <-ctx.Done():
+						return false
+					}
+				})
+			}(rec)
+		}
+	}
+	// records still running when the client goes away have to let go of
+	// their streams before the handler returns
+	defer wg.Wait()
+	start(children)
+
+	for active > 0 {
+		var p part
+		select {
+		case p = <-parts:
+		case <-ctx.Done():
+			return
+		}
+		active += len(p.children)
+		if p.final {
+			active--
+		}
+		if len(p.incremental) == 0 && active > 0 {
+			start(p.children)
+			continue
+		}
+		payload := map[string]interface{}{"hasNext": active > 0}
+		if len(p.incremental) > 0 {
+			payload["incremental"] = p.incremental
+		}
+		if err := writePart(w, payload); err != nil {
+			cancel()
+			return
+		}
+		flusher.Flush()
+		start(p.children)
+	}
+	io.WriteString(w, endBoundary)
+	flusher.Flush()
+}
+
+// plan parses and validates the request, ok is false when it is not a query
+// using @defer or @stream.
+func (h *Handler) plan(opts *handler.RequestOptions) (*plan, bool) {
+	document, err := parser.Parse(parser.ParseParams{Source: opts.Query})
+	if err != nil {
+		return nil, false
+	}
+	var operation *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		op, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if opts.OperationName == "" || (op.Name != nil && op.Name.Value == opts.OperationName) {
+			operation = op
+			break
+		}
+	}
+	if operation == nil || operation.Operation != ast.OperationTypeQuery {
+		return nil, false
+	}
+	if validation := graphql.ValidateDocument(h.Schema, document, nil); !validation.IsValid {
+		return nil, false
+	}
+	pl := newPlan(document, operation, opts.Variables)
+	if !pl.incremental() {
+		return nil, false
+	}
+	return pl, true
+}
+
+func writePart(w io.Writer, payload map[string]interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Println("incremental: encoding payload:", err)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\r\nContent-Type: application/json; charset=utf-8\r\n\r\n%s", partBoundary, body)
+	return err
+}
+
+// executor runs the initial operation and the later parts of one request.
+type executor struct {
+	schema        *graphql.Schema
+	plan          *plan
+	variables     map[string]interface{}
+	formatErrorFn func(err error) gqlerrors.FormattedError
+}
+
+func (ex *executor) execute(ctx context.Context, b *batch, schema *graphql.Schema, root interface{}, document *ast.Document) *graphql.Result {
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:  *schema,
+		Root:    root,
+		AST:     document,
+		Args:    ex.variables,
+		Context: context.WithValue(ctx, batchKey{}, b),
+	})
+}
+
+// executeItem resolves selectionSet on value, which is of type typ.
+func (ex *executor) executeItem(ctx context.Context, b *batch, typ graphql.Type, value interface{}, selectionSet *ast.SelectionSet) *graphql.Result {
+	schema, err := itemSchema(typ)
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	return ex.execute(ctx, b, schema, value, ex.plan.operationFor(itemField(selectionSet)))
+}
+
+// formatErrors runs the errors through FormatErrorFn. Errors of later parts
+// are relative to the item schema, so their path is prefixed with the path
+// of the part.
+func (ex *executor) formatErrors(errs []gqlerrors.FormattedError, prefix []interface{}) []gqlerrors.FormattedError {
+	if len(errs) == 0 {
+		return nil
+	}
+	formatted := make([]gqlerrors.FormattedError, len(errs))
+	for i, err := range errs {
+		if ex.formatErrorFn != nil {
+			formatted[i] = ex.formatErrorFn(err.OriginalError())
+		} else {
+			formatted[i] = err
+		}
+		if prefix != nil && len(err.Path) > 0 {
+			path := err.Path
+			if path[0] == itemFieldName {
+				path = path[1:]
+			}
+			formatted[i].Path = append(append([]interface{}{}, prefix...), path...)
+		}
+	}
+	return formatted
+}
+
+func (ex *executor) formatError(err error, path []interface{}) gqlerrors.FormattedError {
+	located := gqlerrors.NewLocatedError(err, nil)
+	located.Path = path
+	if ex.formatErrorFn != nil {
+		return ex.formatErrorFn(located)
+	}
+	return gqlerrors.FormatError(located)
+}
+
+var (
+	itemSchemasMu sync.Mutex
+	itemSchemas   = make(map[string]*graphql.Schema)
+)
+
+// itemSchema returns a schema whose only root field resolves to the root
+// value as typ, used to run a selection set on a value resolved earlier.
+func itemSchema(typ graphql.Type) (*graphql.Schema, error) {
+	itemSchemasMu.Lock()
+	defer itemSchemasMu.Unlock()
+	if schema, ok := itemSchemas[typ.String()]; ok {
+		return schema, nil
+	}
+	output, ok := typ.(graphql.Output)
+	if !ok {
+		return nil, fmt.Errorf("incremental: %s is not an output type", typ)
+	}
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "IncrementalItem",
+			Fields: graphql.Fields{
+				itemFieldName: &graphql.Field{
+					Type: output,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source, nil
+					},
+				},
+			},
+		}),
+		Directives: Directives(),
+		Extensions: []graphql.Extension{Extension{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	itemSchemas[typ.String()] = &schema
+	return &schema, nil
+}
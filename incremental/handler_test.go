@@ -0,0 +1,210 @@
+package incremental
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+var testItemType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Item",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{Type: graphql.Int},
+	},
+})
+
+var testUserType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "User",
+	Fields: graphql.Fields{
+		"id":   &graphql.Field{Type: graphql.Int},
+		"name": &graphql.Field{Type: graphql.String},
+	},
+})
+
+// newTestSchema serves a user and a list of count items, the list is
+// streamed within limits. A negative count stands for a list whose next item
+// never arrives, like a stalled stream.
+func newTestSchema(t *testing.T, count int, limits Limits) *graphql.Schema {
+	t.Helper()
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"user": &graphql.Field{
+					Type: testUserType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return map[string]interface{}{"id": 1, "name": "ada"}, nil
+					},
+				},
+				"items": &graphql.Field{
+					Type: graphql.NewList(testItemType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						n := 0
+						released := make(chan struct{})
+						next := func() (map[string]interface{}, error) {
+							if count < 0 && n > 0 {
+								<-released
+								return nil, context.Canceled
+							}
+							if count >= 0 && n >= count {
+								return nil, io.EOF
+							}
+							n++
+							return map[string]interface{}{"id": n}, nil
+						}
+						var once sync.Once
+						release := func() {
+							once.Do(func() { close(released) })
+						}
+						if initial, ok, err := Stream(p, next, release, limits); ok {
+							return initial, err
+						}
+						var items []map[string]interface{}
+						for item, err := next(); err == nil; item, err = next() {
+							items = append(items, item)
+						}
+						return items, nil
+					},
+				},
+			},
+		}),
+		Directives: Directives(),
+		Extensions: []graphql.Extension{Extension{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+// serve posts query with a multipart Accept header and returns the decoded
+// parts of the response.
+func serve(t *testing.T, schema *graphql.Schema, query string) []map[string]interface{} {
+	t.Helper()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request passed on to the next handler")
+	})
+	body, _ := json.Marshal(map[string]interface{}{"query": query})
+	r := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "multipart/mixed")
+	w := httptest.NewRecorder()
+	NewHandler(schema, nil, next).ServeHTTP(w, r)
+
+	if got := w.Header().Get("Content-Type"); got != ContentType {
+		t.Fatalf("Content-Type = %q, want %q", got, ContentType)
+	}
+	raw := w.Body.String()
+	if !strings.HasSuffix(raw, endBoundary) {
+		t.Fatalf("response does not end with the closing boundary: %q", raw)
+	}
+	var parts []map[string]interface{}
+	for _, chunk := range strings.Split(strings.TrimSuffix(raw, endBoundary), partBoundary)[1:] {
+		_, payload, ok := strings.Cut(chunk, "\r\n\r\n")
+		if !ok {
+			t.Fatalf("part without headers: %q", chunk)
+		}
+		var part map[string]interface{}
+		if err := json.Unmarshal([]byte(payload), &part); err != nil {
+			t.Fatalf("decoding part %q: %v", payload, err)
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+func jsonEqual(t *testing.T, got map[string]interface{}, want string) {
+	t.Helper()
+	var expected map[string]interface{}
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		gotJSON, _ := json.Marshal(got)
+		t.Errorf("part = %s, want %s", gotJSON, want)
+	}
+}
+
+func TestDefer(t *testing.T) {
+	parts := serve(t, newTestSchema(t, 0, Limits{}), `{ user { id ... @defer(label: "details") { name } } }`)
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2: %v", len(parts), parts)
+	}
+	jsonEqual(t, parts[0], `{"data":{"user":{"id":1}},"hasNext":true}`)
+	jsonEqual(t, parts[1], `{"hasNext":false,"incremental":[{"data":{"name":"ada"},"path":["user"],"label":"details"}]}`)
+}
+
+func TestStream(t *testing.T) {
+	parts := serve(t, newTestSchema(t, 3, Limits{}), `{ items @stream(initialCount: 1) { id } }`)
+	want := []string{
+		`{"data":{"items":[{"id":1}]},"hasNext":true}`,
+		`{"hasNext":true,"incremental":[{"items":[{"id":2}],"path":["items",1]}]}`,
+		`{"hasNext":true,"incremental":[{"items":[{"id":3}],"path":["items",2]}]}`,
+		`{"hasNext":false}`,
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("got %d parts, want %d: %v", len(parts), len(want), parts)
+	}
+	for i := range want {
+		jsonEqual(t, parts[i], want[i])
+	}
+}
+
+func TestStreamMaxItems(t *testing.T) {
+	parts := serve(t, newTestSchema(t, 5, Limits{MaxItems: 2}), `{ items @stream(initialCount: 1) { id } }`)
+	if len(parts) != 3 {
+		t.Fatalf("got %d parts, want 3: %v", len(parts), parts)
+	}
+	last := parts[2]
+	if last["hasNext"] != false {
+		t.Errorf("last part hasNext = %v, want false", last["hasNext"])
+	}
+	incremental, _ := last["incremental"].([]interface{})
+	if len(incremental) != 1 {
+		t.Fatalf("last part = %v, want one incremental result", last)
+	}
+	result := incremental[0].(map[string]interface{})
+	if result["items"] != nil || result["errors"] == nil {
+		t.Errorf("last result = %v, want an error without items", result)
+	}
+}
+
+func TestStreamTimeout(t *testing.T) {
+	parts := serve(t, newTestSchema(t, -1, Limits{Timeout: 50 * time.Millisecond}), `{ items @stream(initialCount: 1) { id } }`)
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2: %v", len(parts), parts)
+	}
+	incremental, _ := parts[1]["incremental"].([]interface{})
+	if len(incremental) != 1 {
+		t.Fatalf("last part = %v, want one incremental result", parts[1])
+	}
+	errs, _ := incremental[0].(map[string]interface{})["errors"].([]interface{})
+	if len(errs) != 1 || !strings.Contains(errs[0].(map[string]interface{})["message"].(string), "timed out") {
+		t.Errorf("errors = %v, want a timeout", errs)
+	}
+}
+
+func TestNotIncremental(t *testing.T) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+	body, _ := json.Marshal(map[string]interface{}{"query": `{ user { id } }`})
+	r := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "multipart/mixed")
+	NewHandler(newTestSchema(t, 0, Limits{}), nil, next).ServeHTTP(httptest.NewRecorder(), r)
+	if !called {
+		t.Error("query without @defer or @stream was not passed on")
+	}
+}
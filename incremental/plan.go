@@ -0,0 +1,140 @@
+package incremental
+
+import (
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// deferredFragment is a fragment removed from the initial operation, it is
+// executed later against the value of the field that encloses it.
+type deferredFragment struct {
+	label     string
+	selection ast.Selection
+}
+
+// plan is the incremental part of an operation. Deferred fragments are keyed
+// by the field they are selected in, fragments at the root of the operation
+// are kept apart.
+type plan struct {
+	document  *ast.Document
+	operation *ast.OperationDefinition
+	variables map[string]interface{}
+	deferred  map[*ast.Field][]*deferredFragment
+	root      []*deferredFragment
+	streams   bool
+}
+
+// newPlan finds the active @defer and @stream directives of operation and
+// removes the deferred fragments from the document. The document is changed
+// in place, the removed selections stay reachable through the plan.
+func newPlan(document *ast.Document, operation *ast.OperationDefinition, variables map[string]interface{}) *plan {
+	p := &plan{
+		document:  document,
+		operation: operation,
+		variables: variables,
+		deferred:  make(map[*ast.Field][]*deferredFragment),
+	}
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	removed := make(map[*ast.SelectionSet]map[ast.Selection]bool)
+	type visit struct {
+		enclosing *ast.Field
+		fragment  string
+	}
+	seen := make(map[visit]bool)
+
+	var walk func(set *ast.SelectionSet, enclosing *ast.Field)
+	walk = func(set *ast.SelectionSet, enclosing *ast.Field) {
+		if set == nil {
+			return
+		}
+		for _, selection := range set.Selections {
+			var directives []*ast.Directive
+			switch selection := selection.(type) {
+			case *ast.Field:
+				if _, ok := activeDirective(selection.Directives, StreamDirective.Name, variables); ok {
+					p.streams = true
+				}
+				walk(selection.SelectionSet, selection)
+				continue
+			case *ast.InlineFragment:
+				directives = selection.Directives
+			case *ast.FragmentSpread:
+				directives = selection.Directives
+			}
+
+			if args, ok := activeDirective(directives, DeferDirective.Name, variables); ok {
+				fragment := &deferredFragment{label: args.label, selection: selection}
+				if enclosing == nil {
+					p.root = append(p.root, fragment)
+				} else {
+					p.deferred[enclosing] = append(p.deferred[enclosing], fragment)
+				}
+				if removed[set] == nil {
+					removed[set] = make(map[ast.Selection]bool)
+				}
+				removed[set][selection] = true
+			}
+
+			// deferred or not, the fragment can hold more directives
+			switch selection := selection.(type) {
+			case *ast.InlineFragment:
+				walk(selection.SelectionSet, enclosing)
+			case *ast.FragmentSpread:
+				v := visit{enclosing: enclosing, fragment: selection.Name.Value}
+				if fragment, ok := fragments[v.fragment]; ok && !seen[v] {
+					seen[v] = true
+					walk(fragment.SelectionSet, enclosing)
+				}
+			}
+		}
+	}
+	walk(operation.SelectionSet, nil)
+
+	for set, selections := range removed {
+		kept := make([]ast.Selection, 0, len(set.Selections))
+		for _, selection := range set.Selections {
+			if !selections[selection] {
+				kept = append(kept, selection)
+			}
+		}
+		set.Selections = kept
+	}
+	return p
+}
+
+// incremental reports whether anything of the operation can be delivered
+// later.
+func (p *plan) incremental() bool {
+	return p.streams || len(p.root) > 0 || len(p.deferred) > 0
+}
+
+// operationFor builds a query operation selecting selections, with the
+// variable definitions of the planned operation.
+func (p *plan) operationFor(selections ...ast.Selection) *ast.Document {
+	definitions := []ast.Node{
+		ast.NewOperationDefinition(&ast.OperationDefinition{
+			Operation:           ast.OperationTypeQuery,
+			VariableDefinitions: p.operation.VariableDefinitions,
+			SelectionSet:        ast.NewSelectionSet(&ast.SelectionSet{Selections: selections}),
+		}),
+	}
+	for _, definition := range p.document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			definitions = append(definitions, fragment)
+		}
+	}
+	return ast.NewDocument(&ast.Document{Definitions: definitions})
+}
+
+// itemField selects selectionSet on the item root field of an item schema.
+func itemField(selectionSet *ast.SelectionSet) *ast.Field {
+	return ast.NewField(&ast.Field{
+		Name:         ast.NewName(&ast.Name{Value: itemFieldName}),
+		SelectionSet: selectionSet,
+	})
+}
@@ -0,0 +1,351 @@
+package incremental
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const itemFieldName = "item"
+
+// record is work delivered after the payload that registered it.
+type record interface {
+	run(ctx context.Context, ex *executor, emit func(part) bool)
+}
+
+// part is one payload of the response. children are the records registered
+// while it was executed, final marks the last part of a record.
+type part struct {
+	incremental []map[string]interface{}
+	children    []record
+	final       bool
+}
+
+// batch collects the records registered during one execution.
+type batch struct {
+	mu      sync.Mutex
+	plan    *plan
+	records []record
+}
+
+func (b *batch) add(r record) {
+	b.mu.Lock()
+	b.records = append(b.records, r)
+	b.mu.Unlock()
+}
+
+func (b *batch) take() []record {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	records := b.records
+	b.records = nil
+	return records
+}
+
+type batchKey struct{}
+
+func batchFrom(ctx context.Context) *batch {
+	if ctx == nil {
+		return nil
+	}
+	b, _ := ctx.Value(batchKey{}).(*batch)
+	return b
+}
+
+// deferRecord executes a deferred fragment against the value of the field it
+// was selected in.
+type deferRecord struct {
+	fragment *deferredFragment
+	path     []interface{}
+	typ      graphql.Type
+	value    interface{}
+	root     bool
+}
+
+func (r *deferRecord) run(ctx context.Context, ex *executor, emit func(part) bool) {
+	value := r.value
+	if thunk, ok := value.(func() (interface{}, error)); ok {
+		resolved, err := thunk()
+		if err != nil {
+			emit(part{final: true, incremental: []map[string]interface{}{
+				r.payload(nil, []gqlerrors.FormattedError{ex.formatError(err, r.path)}),
+			}})
+			return
+		}
+		value = resolved
+	}
+
+	var data interface{}
+	var errs []gqlerrors.FormattedError
+	b := &batch{plan: ex.plan}
+	if r.root {
+		result := ex.execute(ctx, b, ex.schema, nil, ex.plan.operationFor(r.fragment.selection))
+		data, errs = result.Data, result.Errors
+	} else {
+		selection := ast.NewSelectionSet(&ast.SelectionSet{Selections: []ast.Selection{r.fragment.selection}})
+		result := ex.executeItem(ctx, b, r.typ, value, selection)
+		data, errs = itemData(result), result.Errors
+	}
+	emit(part{final: true, children: b.take(), incremental: []map[string]interface{}{
+		r.payload(data, ex.formatErrors(errs, r.path)),
+	}})
+}
+
+func (r *deferRecord) payload(data interface{}, errs []gqlerrors.FormattedError) map[string]interface{} {
+	payload := map[string]interface{}{
+		"data": data,
+		"path": r.path,
+	}
+	if r.fragment.label != "" {
+		payload["label"] = r.fragment.label
+	}
+	if len(errs) > 0 {
+		payload["errors"] = errs
+	}
+	return payload
+}
+
+// Limits bound a streamed list across all of its payloads.
+type Limits struct {
+	// MaxItems caps the items of the list, the initial ones included. 0
+	// means no cap.
+	MaxItems int
+	// Timeout bounds the time from the field resolving to its last item. 0
+	// means no timeout.
+	Timeout time.Duration
+}
+
+// streamRecord delivers the remaining items of a streamed list one payload
+// per item, as they come out of next.
+type streamRecord struct {
+	label    string
+	path     []interface{}
+	typ      graphql.Type
+	field    *ast.Field
+	index    int
+	maxItems int
+	deadline time.Time
+	next     func() (interface{}, error)
+	release  func()
+}
+
+func (r *streamRecord) run(ctx context.Context, ex *executor, emit func(part) bool) {
+	defer r.release()
+	if !r.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, r.deadline)
+		defer cancel()
+		// next may be blocked on a stream, releasing it unblocks next
+		defer context.AfterFunc(ctx, r.release)()
+	}
+	for {
+		item, err := r.next()
+		if err == io.EOF {
+			emit(part{final: true})
+			return
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			err = apperror.New(apperror.CodeTimeout, fmt.Sprintf("list timed out after %d items", r.index))
+		} else if err == nil && r.maxItems > 0 && r.index >= r.maxItems {
+			err = apperror.New(apperror.CodeFailedPrecondition, fmt.Sprintf("list truncated after %d items", r.maxItems))
+		}
+		path := append(append([]interface{}{}, r.path...), r.index)
+		if err != nil {
+			emit(part{final: true, incremental: []map[string]interface{}{
+				r.payload(nil, path, []gqlerrors.FormattedError{ex.formatError(err, path)}),
+			}})
+			return
+		}
+
+		b := &batch{plan: ex.plan}
+		result := ex.executeItem(ctx, b, r.typ, item, r.field.SelectionSet)
+		var items []interface{}
+		if result.Data != nil {
+			items = []interface{}{itemData(result)}
+			// the item schema selects the item through its own field, the
+			// fragments deferred in the streamed field are added here
+			for _, fragment := range ex.plan.deferred[r.field] {
+				deferValue(b, fragment, path, r.typ, item)
+			}
+		}
+		if !emit(part{children: b.take(), incremental: []map[string]interface{}{
+			r.payload(items, path, ex.formatErrors(result.Errors, path)),
+		}}) {
+			return
+		}
+		r.index++
+	}
+}
+
+func (r *streamRecord) payload(items []interface{}, path []interface{}, errs []gqlerrors.FormattedError) map[string]interface{} {
+	payload := map[string]interface{}{
+		"items": items,
+		"path":  path,
+	}
+	if r.label != "" {
+		payload["label"] = r.label
+	}
+	if len(errs) > 0 {
+		payload["errors"] = errs
+	}
+	return payload
+}
+
+func itemData(result *graphql.Result) interface{} {
+	if data, ok := result.Data.(map[string]interface{}); ok {
+		return data[itemFieldName]
+	}
+	return nil
+}
+
+// Stream serves a list field requested with @stream in an incremental
+// request. It receives the initialCount items the field resolves to and
+// leaves the rest of next to later payloads, within limits. ok is false when
+// the field is not streamed, the caller then resolves the whole list itself.
+// release is called once next is no longer used.
+func Stream[T any](p graphql.ResolveParams, next func() (T, error), release func(), limits Limits) (initial []T, ok bool, err error) {
+	b := batchFrom(p.Context)
+	if b == nil || len(p.Info.FieldASTs) == 0 {
+		return nil, false, nil
+	}
+	field := p.Info.FieldASTs[0]
+	args, ok := activeDirective(field.Directives, StreamDirective.Name, b.plan.variables)
+	if !ok {
+		return nil, false, nil
+	}
+
+	var deadline time.Time
+	if limits.Timeout > 0 {
+		deadline = time.Now().Add(limits.Timeout)
+	}
+	initialCount := args.initialCount
+	if limits.MaxItems > 0 && initialCount > limits.MaxItems {
+		initialCount = limits.MaxItems
+	}
+	initial = []T{}
+	for len(initial) < initialCount {
+		item, err := next()
+		if err == io.EOF {
+			release()
+			return initial, true, nil
+		}
+		if err != nil {
+			release()
+			return nil, true, err
+		}
+		initial = append(initial, item)
+	}
+	b.add(&streamRecord{
+		label:    args.label,
+		path:     p.Info.Path.AsArray(),
+		typ:      listItemType(p.Info.ReturnType),
+		field:    field,
+		index:    len(initial),
+		maxItems: limits.MaxItems,
+		deadline: deadline,
+		next: func() (interface{}, error) {
+			return next()
+		},
+		release: release,
+	})
+	return initial, true, nil
+}
+
+func listItemType(typ graphql.Type) graphql.Type {
+	if nonNull, ok := typ.(*graphql.NonNull); ok {
+		typ = nonNull.OfType
+	}
+	if list, ok := typ.(*graphql.List); ok {
+		return list.OfType
+	}
+	return typ
+}
+
+// Extension picks up the values of the fields that enclose deferred
+// fragments, it has to be part of the schema.
+type Extension struct{}
+
+func (Extension) Init(ctx context.Context, p *graphql.Params) context.Context {
+	return ctx
+}
+
+func (Extension) Name() string {
+	return "incremental"
+}
+
+func (Extension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (Extension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (Extension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(*graphql.Result) {}
+}
+
+func (Extension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	b := batchFrom(ctx)
+	if b == nil {
+		return ctx, func(interface{}, error) {}
+	}
+	var fragments []*deferredFragment
+	for _, field := range info.FieldASTs {
+		fragments = append(fragments, b.plan.deferred[field]...)
+	}
+	if len(fragments) == 0 {
+		return ctx, func(interface{}, error) {}
+	}
+	path := info.Path.AsArray()
+	typ := info.ReturnType
+	return ctx, func(value interface{}, err error) {
+		if err != nil || value == nil {
+			return
+		}
+		for _, fragment := range fragments {
+			deferValue(b, fragment, path, typ, value)
+		}
+	}
+}
+
+func (Extension) HasResult() bool {
+	return false
+}
+
+func (Extension) GetResult(context.Context) interface{} {
+	return nil
+}
+
+// deferValue registers fragment for value, once per item when the field is a
+// list.
+func deferValue(b *batch, fragment *deferredFragment, path []interface{}, typ graphql.Type, value interface{}) {
+	if nonNull, ok := typ.(*graphql.NonNull); ok {
+		typ = nonNull.OfType
+	}
+	list, ok := typ.(*graphql.List)
+	if !ok {
+		b.add(&deferRecord{fragment: fragment, path: path, typ: typ, value: value})
+		return
+	}
+	items := reflect.ValueOf(value)
+	if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+		return
+	}
+	for i := 0; i < items.Len(); i++ {
+		item := items.Index(i).Interface()
+		if item == nil || (items.Index(i).Kind() == reflect.Ptr && items.Index(i).IsNil()) {
+			continue
+		}
+		itemPath := append(append([]interface{}{}, path...), i)
+		deferValue(b, fragment, itemPath, list.OfType, item)
+	}
+}
@@ -0,0 +1,126 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+type Result[V any] struct {
+	Value V
+	Err   error
+}
+
+// BatchFunc loads every key at once. The returned results must be in the same
+// order as keys.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) []Result[V]
+
+type entry[V any] struct {
+	done   chan struct{}
+	result Result[V]
+}
+
+// Loader deduplicates and batches lookups by key and caches the results. A
+// loader is meant to live for a single request.
+type Loader[K comparable, V any] struct {
+	mu      sync.Mutex
+	batch   BatchFunc[K, V]
+	stats   *Stats
+	cache   map[K]*entry[V]
+	pending []K
+}
+
+func New[K comparable, V any](name string, batch BatchFunc[K, V]) *Loader[K, V] {
+	return &Loader[K, V]{
+		batch: batch,
+		stats: statsFor(name),
+		cache: make(map[K]*entry[V]),
+	}
+}
+
+// Load queues key for the next batch and returns a thunk that dispatches the
+// batch on first call. The thunk can be returned from a graphql resolver so
+// that sibling fields are collected into one batch.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) func() (V, error) {
+	l.mu.Lock()
+	e, ok := l.cache[key]
+	if ok {
+		l.stats.hits.Add(1)
+	} else {
+		l.stats.misses.Add(1)
+		e = &entry[V]{done: make(chan struct{})}
+		l.cache[key] = e
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.dispatch(ctx)
+		<-e.done
+		return e.result.Value, e.result.Err
+	}
+}
+
+func (l *Loader[K, V]) dispatch(ctx context.Context) {
+	l.mu.Lock()
+	keys := l.pending
+	l.pending = nil
+	entries := make([]*entry[V], len(keys))
+	for i, key := range keys {
+		entries[i] = l.cache[key]
+	}
+	l.mu.Unlock()
+
+	if len(keys) == 0 {
+		return
+	}
+	l.stats.batches.Add(1)
+
+	// a panicking batch fails its entries, the thunks waiting on them would
+	// hang otherwise
+	var results []Result[V]
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("loader: batch of %d keys panicked: %v", len(keys), r)
+			results = make([]Result[V], len(keys))
+			for i := range results {
+				results[i].Err = fmt.Errorf("loader: batch panicked: %v", r)
+			}
+		}
+		for i, e := range entries {
+			if i < len(results) {
+				e.result = results[i]
+			}
+			close(e.done)
+		}
+	}()
+	results = l.batch(ctx, keys)
+}
+
+// Concurrent builds a BatchFunc out of a single key fetch, running at most
+// limit fetches at the same time.
+func Concurrent[K comparable, V any](limit int, fetch func(ctx context.Context, key K) (V, error)) BatchFunc[K, V] {
+	if limit < 1 {
+		limit = 1
+	}
+	return func(ctx context.Context, keys []K) []Result[V] {
+		results := make([]Result[V], len(keys))
+		sem := make(chan struct{}, limit)
+		var wg sync.WaitGroup
+		for i, key := range keys {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, key K) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				value, err := fetch(ctx, key)
+				results[i] = Result[V]{Value: value, Err: err}
+			}(i, key)
+		}
+		wg.Wait()
+		return results
+	}
+}
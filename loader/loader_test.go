@@ -0,0 +1,56 @@
+package loader
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoadBatchesAndCaches(t *testing.T) {
+	var batches [][]int
+	l := New("test", func(ctx context.Context, keys []int) []Result[int] {
+		batches = append(batches, keys)
+		results := make([]Result[int], len(keys))
+		for i, key := range keys {
+			results[i].Value = key * 10
+		}
+		return results
+	})
+	ctx := context.Background()
+	keys := []int{1, 2, 1}
+	thunks := make([]func() (int, error), len(keys))
+	for i, key := range keys {
+		thunks[i] = l.Load(ctx, key)
+	}
+	for i, thunk := range thunks {
+		if got, err := thunk(); err != nil || got != keys[i]*10 {
+			t.Errorf("key %d = %d, %v, want %d", keys[i], got, err, keys[i]*10)
+		}
+	}
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Errorf("batches = %v, want one batch of keys 1 and 2", batches)
+	}
+}
+
+func TestPanickingBatchFailsEveryKey(t *testing.T) {
+	l := New("test-panic", func(ctx context.Context, keys []int) []Result[int] {
+		panic("backend exploded")
+	})
+	ctx := context.Background()
+	thunks := []func() (int, error){l.Load(ctx, 1), l.Load(ctx, 2)}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, thunk := range thunks {
+			if _, err := thunk(); err == nil {
+				t.Error("thunk of a panicking batch did not fail")
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("thunks of a panicking batch hang")
+	}
+}
@@ -0,0 +1,55 @@
+package loader
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats counts loader usage across requests, published through expvar as
+// "loaders". The gateway serves expvar on its admin listener only.
+type Stats struct {
+	hits    atomic.Int64
+	misses  atomic.Int64
+	batches atomic.Int64
+}
+
+var (
+	statsMu  sync.Mutex
+	allStats = map[string]*Stats{}
+)
+
+func init() {
+	expvar.Publish("loaders", expvar.Func(snapshot))
+}
+
+func statsFor(name string) *Stats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	s, ok := allStats[name]
+	if !ok {
+		s = &Stats{}
+		allStats[name] = s
+	}
+	return s
+}
+
+func snapshot() interface{} {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	out := make(map[string]interface{}, len(allStats))
+	for name, s := range allStats {
+		hits, misses := s.hits.Load(), s.misses.Load()
+		ratio := 0.0
+		if hits+misses > 0 {
+			ratio = float64(hits) / float64(hits+misses)
+		}
+		out[name] = map[string]interface{}{
+			"hits":     hits,
+			"misses":   misses,
+			"batches":  s.batches.Load(),
+			"hitRatio": ratio,
+		}
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// CacheHeaders sets ETag and Cache-Control on successful GET responses and
+// answers matching If-None-Match requests with 304. Anonymous responses are
+// publicly cacheable for maxAge, anything tied to a session or carrying
+// errors is not. Responses vary on the cookie so a shared cache never hands
+// an anonymous response to a logged in user.
+func CacheHeaders(maxAge time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// websocket upgrades and multipart/mixed responses are streamed
+		if r.Method != http.MethodGet || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+			strings.Contains(r.Header.Get("Accept"), "multipart/mixed") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Cookie")
+		buf := &bufferedWriter{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+
+		if buf.status != http.StatusOK {
+			w.WriteHeader(buf.status)
+			w.Write(buf.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(buf.body.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		w.Header().Set("ETag", etag)
+
+		switch {
+		case w.Header().Get("Set-Cookie") != "":
+			w.Header().Set("Cache-Control", "no-store")
+		case hasErrors(buf.body.Bytes()):
+			w.Header().Set("Cache-Control", "no-store")
+		case HasSessionCookie(r):
+			w.Header().Set("Cache-Control", "private, no-cache")
+		default:
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+		}
+
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.body.Bytes())
+	})
+}
+
+// hasErrors reports whether a GraphQL response body has an errors entry.
+func hasErrors(body []byte) bool {
+	var result struct {
+		Errors json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return true
+	}
+	return len(result.Errors) > 0 && string(result.Errors) != "null"
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
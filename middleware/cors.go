@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CORSConfig struct {
+	// AllowedOrigins are origins like https://shop.example.com, a * matches
+	// any run of characters within the host, so https://*.example.com
+	// allows every subdomain. A lone * allows every origin.
+	AllowedOrigins   []string
+	AllowCredentials bool
+	AllowedHeaders   []string
+	MaxAge           time.Duration
+}
+
+var corsMethods = []string{http.MethodGet, http.MethodPost}
+
+// CORS answers preflight requests and adds the CORS headers to requests from
+// allowed origins. Requests from other origins pass through without them, so
+// the browser keeps the response from the page.
+func CORS(cfg CORSConfig, next http.Handler) (http.Handler, error) {
+	patterns, anyOrigin := originPatterns(cfg.AllowedOrigins)
+	if anyOrigin && cfg.AllowCredentials {
+		return nil, errors.New("CORS cannot allow credentials for every origin")
+	}
+
+	allowedHeaders := make(map[string]bool, len(cfg.AllowedHeaders))
+	for _, header := range cfg.AllowedHeaders {
+		allowedHeaders[http.CanonicalHeaderKey(header)] = true
+	}
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	allowed := func(origin string) bool {
+		if origin == "null" {
+			return false
+		}
+		if anyOrigin {
+			return true
+		}
+		return matchOrigin(patterns, origin)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		if !allowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if cfg.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !containsMethod(r.Header.Get("Access-Control-Request-Method")) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var headers []string
+		for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+			if header == "" {
+				continue
+			}
+			if !allowedHeaders[header] {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			headers = append(headers, header)
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(corsMethods, ", "))
+		if len(headers) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+		}
+		if cfg.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}), nil
+}
+
+func containsMethod(method string) bool {
+	for _, m := range corsMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// originPatterns compiles the allowed origins, anyOrigin reports a lone *.
+func originPatterns(origins []string) (patterns []*regexp.Regexp, anyOrigin bool) {
+	for _, origin := range origins {
+		origin = strings.TrimSuffix(strings.ToLower(origin), "/")
+		if origin == "*" {
+			anyOrigin = true
+			continue
+		}
+		pattern := strings.ReplaceAll(regexp.QuoteMeta(origin), `\*`, `[^/:]*`)
+		patterns = append(patterns, regexp.MustCompile("^"+pattern+"$"))
+	}
+	return patterns, anyOrigin
+}
+
+func matchOrigin(patterns []*regexp.Regexp, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// WebSocketOrigin returns the origin check of websocket upgrades. Browsers
+// send the session cookie with the upgrade whatever the page, so only the
+// gateway's own origin, the CORS allowed origins and the CSRF trusted origins
+// are let through. A lone * in corsOrigins does not count, CORS never sends
+// credentials to every origin either. Clients that send no Origin are not
+// browsers and are let through.
+func WebSocketOrigin(corsOrigins, trustedOrigins []string) func(r *http.Request) bool {
+	patterns, _ := originPatterns(corsOrigins)
+	trusted := make(map[string]bool, len(trustedOrigins))
+	for _, origin := range trustedOrigins {
+		trusted[strings.TrimSuffix(strings.ToLower(origin), "/")] = true
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		return sameOrigin(origin, r) || trusted[strings.ToLower(origin)] || matchOrigin(patterns, origin)
+	}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/handler"
+)
+
+// CSRFHeader has to be sent with every mutation authenticated by the session
+// cookie. Browsers only let a page set a custom header after a CORS
+// preflight, which a foreign site does not pass.
+const CSRFHeader = "X-CSRF-Protection"
+
+// CSRF rejects mutations that ride on the session cookie unless they carry
+// CSRFHeader and, when the browser tells, come from the gateway's own origin
+// or one of trustedOrigins. Requests without the cookie are left alone, a
+// forged request cannot authenticate any other way.
+func CSRF(trustedOrigins []string, next http.Handler) http.Handler {
+	trusted := make(map[string]bool, len(trustedOrigins))
+	for _, origin := range trustedOrigins {
+		trusted[strings.TrimSuffix(strings.ToLower(origin), "/")] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !HasSessionCookie(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !hasMutation(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Header.Get(CSRFHeader) == "" {
+			csrfError(w, "missing "+CSRFHeader+" header")
+			return
+		}
+		if origin := requestOrigin(r); origin != "" && !trusted[origin] && !sameOrigin(origin, r) {
+			csrfError(w, "origin "+origin+" is not trusted")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasMutation reports whether any operation of r is a mutation. It reads the
+// request the same way the graphql and batch handlers do, the body is put
+// back afterwards.
+func hasMutation(r *http.Request) bool {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+	}
+	defer func() {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}()
+
+	type operation struct {
+		Query         string `json:"query"`
+		OperationName string `json:"operationName"`
+	}
+	var operations []operation
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		json.Unmarshal(trimmed, &operations)
+	}
+
+	clone := r.Clone(r.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	opts := handler.NewRequestOptions(clone)
+	operations = append(operations, operation{Query: opts.Query, OperationName: opts.OperationName})
+
+	for _, op := range operations {
+		if operationType(op.Query, op.OperationName) == ast.OperationTypeMutation {
+			return true
+		}
+	}
+	return false
+}
+
+// requestOrigin returns the origin the browser reports through the Origin
+// header, or through the Referer when there is no Origin.
+func requestOrigin(r *http.Request) string {
+	if origin := r.Header.Get("Origin"); origin != "" && origin != "null" {
+		return strings.ToLower(origin)
+	}
+	if referer := r.Header.Get("Referer"); referer != "" {
+		if u, err := url.Parse(referer); err == nil && u.Host != "" {
+			return strings.ToLower(u.Scheme + "://" + u.Host)
+		}
+	}
+	return r.Header.Get("Origin")
+}
+
+func sameOrigin(origin string, r *http.Request) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func csrfError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []gqlerrors.FormattedError{{
+			Message:    message,
+			Extensions: map[string]interface{}{"code": string(apperror.CodeForbidden)},
+		}},
+	})
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// QueriesOnlyOverGET rejects GET requests for anything but a query. GET
+// responses may be cached by the browser or a CDN and GET requests are sent
+// by plain links, so mutations have to go over POST.
+func QueriesOnlyOverGET(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Query().Get("query") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		query := r.URL.Query()
+		operation := operationType(query.Get("query"), query.Get("operationName"))
+		if operation == "" || operation == ast.OperationTypeQuery {
+			// parse errors are reported by the graphql handler
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Allow", http.MethodPost)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"errors": []gqlerrors.FormattedError{{
+				Message:    "only queries can be sent with GET, use POST for " + operation + "s",
+				Extensions: map[string]interface{}{"code": string(apperror.CodeBadUserInput)},
+			}},
+		})
+	})
+}
+
+// operationType returns the type of the operation that would run, or "" when
+// the document does not parse or the operation is not found.
+func operationType(query, operationName string) string {
+	document, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return ""
+	}
+	for _, definition := range document.Definitions {
+		op, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			return op.Operation
+		}
+	}
+	return ""
+}
@@ -2,10 +2,11 @@ package middleware
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/Nishad4140/api_gateway/apperror"
 	"github.com/Nishad4140/api_gateway/authorize"
 	"github.com/graphql-go/graphql"
 )
@@ -22,31 +23,33 @@ func UserMiddleware(next graphql.FieldResolveFn) graphql.FieldResolveFn {
 	return func(p graphql.ResolveParams) (interface{}, error) {
 
 		r := p.Context.Value("request").(*http.Request)
-		cookie, err := r.Cookie("jwtToken")
+		token, err := sessionToken(r)
 		if err != nil {
-			return nil, err
-		}
-		if cookie == nil {
-			return nil, fmt.Errorf("not logged in")
+			return nil, apperror.New(apperror.CodeUnauthenticated, "not logged in")
 		}
 
 		ctx := p.Context
 
-		token := cookie.Value
-
 		auth, err := authorize.ValidateToken(token, secret)
 		if err != nil {
 			fmt.Println(err.Error())
-			return nil, err
+			return nil, apperror.New(apperror.CodeUnauthenticated, "session is invalid or expired")
 		}
 
 		userIDval := auth["userId"].(uint)
 
 		if userIDval < 1 {
-			return nil, errors.New("userID is not valid")
+			return nil, apperror.New(apperror.CodeUnauthenticated, "userID is not valid")
 		}
 
 		ctx = context.WithValue(ctx, "userId", userIDval)
+		ctx = context.WithValue(ctx, "isAdmin", auth["isAdmin"].(bool))
+		ctx = context.WithValue(ctx, "principal", &Principal{
+			UserId:    userIDval,
+			IsAdmin:   auth["isAdmin"].(bool),
+			IsSuAdmin: auth["isSuAdmin"].(bool),
+			ExpiresAt: time.Unix(auth["expiresAt"].(int64), 0),
+		})
 
 		p.Context = ctx
 
@@ -58,30 +61,25 @@ func AdminMiddleware(next graphql.FieldResolveFn) graphql.FieldResolveFn {
 	return func(p graphql.ResolveParams) (interface{}, error) {
 
 		r := p.Context.Value("request").(*http.Request)
-		cookie, err := r.Cookie("jwtToken")
+		token, err := sessionToken(r)
 		if err != nil {
-			return nil, err
-		}
-		if cookie == nil {
-			return nil, fmt.Errorf("not logged in")
+			return nil, apperror.New(apperror.CodeUnauthenticated, "not logged in")
 		}
 
 		ctx := p.Context
 
-		token := cookie.Value
-
 		auth, err := authorize.ValidateToken(token, secret)
 		if err != nil {
 			fmt.Println(err.Error())
-			return nil, err
+			return nil, apperror.New(apperror.CodeUnauthenticated, "session is invalid or expired")
 		}
 
 		userIDval := auth["userId"].(uint)
 		if userIDval < 1 {
-			return nil, fmt.Errorf("invalid userID")
+			return nil, apperror.New(apperror.CodeUnauthenticated, "invalid userID")
 		}
 		if !auth["isAdmin"].(bool) {
-			return nil, fmt.Errorf("not an admin")
+			return nil, apperror.New(apperror.CodeForbidden, "not an admin")
 		}
 
 		ctx = context.WithValue(ctx, "userId", userIDval)
@@ -96,29 +94,24 @@ func SupAdminMiddleware(next graphql.FieldResolveFn) graphql.FieldResolveFn {
 	return func(p graphql.ResolveParams) (interface{}, error) {
 
 		r := p.Context.Value("request").(*http.Request)
-		cookie, err := r.Cookie("jwtToken")
+		token, err := sessionToken(r)
 		if err != nil {
-			return nil, err
-		}
-		if cookie == nil {
-			return nil, fmt.Errorf("you are not logged in")
+			return nil, apperror.New(apperror.CodeUnauthenticated, "you are not logged in")
 		}
 
 		ctx := p.Context
-
-		token := cookie.Value
 		auth, err := authorize.ValidateToken(token, secret)
 		if err != nil {
 			fmt.Println(err.Error())
-			return nil, err
+			return nil, apperror.New(apperror.CodeUnauthenticated, "session is invalid or expired")
 		}
 
 		userIDval := auth["userId"].(uint)
 		if userIDval < 1 {
-			return nil, fmt.Errorf("invalid userID")
+			return nil, apperror.New(apperror.CodeUnauthenticated, "invalid userID")
 		}
 		if !auth["isSuAdmin"].(bool) {
-			return nil, fmt.Errorf("you are not an super admin to perform this action")
+			return nil, apperror.New(apperror.CodeForbidden, "you are not an super admin to perform this action")
 		}
 
 		ctx = context.WithValue(ctx, "userId", userIDval)
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Nishad4140/api_gateway/authorize"
+)
+
+// Principal is the caller of a request as far as the session cookie tells.
+// UserId is zero for anonymous callers.
+type Principal struct {
+	UserId    uint
+	IsAdmin   bool
+	IsSuAdmin bool
+	ExpiresAt time.Time
+}
+
+func (p *Principal) Authenticated() bool {
+	return p != nil && p.UserId > 0
+}
+
+// Authenticate resolves the principal of r once per request, the /graphql
+// handler stores it in the context under "principal". It never returns nil.
+func Authenticate(r *http.Request) *Principal {
+	token, err := sessionToken(r)
+	if err != nil {
+		return &Principal{}
+	}
+	return principalFromToken(token)
+}
+
+func principalFromToken(token string) *Principal {
+	auth, err := authorize.ValidateToken(token, secret)
+	if err != nil {
+		return &Principal{}
+	}
+	return &Principal{
+		UserId:    auth["userId"].(uint),
+		IsAdmin:   auth["isAdmin"].(bool),
+		IsSuAdmin: auth["isSuAdmin"].(bool),
+		ExpiresAt: time.Unix(auth["expiresAt"].(int64), 0),
+	}
+}
+
+func PrincipalFrom(ctx context.Context) *Principal {
+	if principal, ok := ctx.Value("principal").(*Principal); ok {
+		return principal
+	}
+	return &Principal{}
+}
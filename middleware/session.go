@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Nishad4140/api_gateway/settings"
+)
+
+const hostPrefix = "__Host-"
+
+// SessionCookie fills in the defaults of cfg and checks that browsers accept
+// the cookie it describes. The cookie is always HttpOnly.
+func SessionCookie(cfg settings.Cookie) (settings.Cookie, error) {
+	if cfg.Name == "" {
+		return cfg, errors.New("session cookie name is empty")
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.HostPrefix {
+		if !cfg.Secure || cfg.Path != "/" || cfg.Domain != "" {
+			return cfg, errors.New("a __Host- session cookie must be Secure, have Path / and no Domain")
+		}
+		if !strings.HasPrefix(cfg.Name, hostPrefix) {
+			cfg.Name = hostPrefix + cfg.Name
+		}
+	}
+	if cfg.SameSite == http.SameSiteNoneMode && !cfg.Secure {
+		return cfg, errors.New("a SameSite=None session cookie must be Secure")
+	}
+	return cfg, nil
+}
+
+// ParseSameSite maps "strict", "lax" and "none" to the cookie mode.
+func ParseSameSite(val string) (http.SameSite, error) {
+	switch strings.ToLower(val) {
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, errors.New("unknown SameSite mode " + val)
+}
+
+func SessionCookieName(ctx context.Context) string {
+	return settings.From(ctx).SessionCookie.Name
+}
+
+// sessionToken returns the token of the session cookie of r.
+func sessionToken(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(SessionCookieName(r.Context()))
+	if err != nil {
+		return "", err
+	}
+	return cookie.Value, nil
+}
+
+func HasSessionCookie(r *http.Request) bool {
+	_, err := sessionToken(r)
+	return err == nil
+}
+
+// StartSession issues the session cookie for token, expiring with the token,
+// and switches the principal of the current request to it so the rest of the
+// request sees the user that just logged in.
+func StartSession(ctx context.Context, w http.ResponseWriter, token string) {
+	principal := principalFromToken(token)
+	cookie := newSessionCookie(ctx, token)
+	cookie.Expires = principal.ExpiresAt
+	http.SetCookie(w, cookie)
+
+	if current, ok := ctx.Value("principal").(*Principal); ok {
+		*current = *principal
+	}
+}
+
+// EndSession clears the session cookie, with the same attributes it was set
+// with so that the browser replaces it.
+func EndSession(ctx context.Context, w http.ResponseWriter) {
+	cookie := newSessionCookie(ctx, "")
+	cookie.Expires = time.Unix(0, 0)
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+
+	if current, ok := ctx.Value("principal").(*Principal); ok {
+		*current = Principal{}
+	}
+}
+
+func newSessionCookie(ctx context.Context, value string) *http.Cookie {
+	cfg := settings.From(ctx).SessionCookie
+	return &http.Cookie{
+		Name:     cfg.Name,
+		Value:    value,
+		Path:     cfg.Path,
+		Domain:   cfg.Domain,
+		Secure:   cfg.Secure,
+		HttpOnly: true,
+		SameSite: cfg.SameSite,
+	}
+}
@@ -0,0 +1,104 @@
+// Package settings holds the settings that can change while the gateway
+// runs. They are published as one immutable snapshot, a request takes the
+// snapshot once and reads every setting from it.
+package settings
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Cookie holds the attributes of the session cookie.
+type Cookie struct {
+	Name     string
+	Domain   string
+	Path     string
+	Secure   bool
+	SameSite http.SameSite
+	// HostPrefix names the cookie __Host-<Name>, which browsers only accept
+	// when it is Secure, has Path / and no Domain.
+	HostPrefix bool
+}
+
+// Streams bound how list resolvers drain the gRPC streams.
+type Streams struct {
+	MaxItems int
+	Timeout  time.Duration
+	// Policy is "fail" or "partial"
+	Policy string
+}
+
+// LowStock holds the global low stock threshold and the per product
+// overrides. A threshold of 0 disables the alert.
+type LowStock struct {
+	Threshold  int32
+	PerProduct map[uint32]int32
+}
+
+type Settings struct {
+	SessionCookie Cookie
+	// StockCheck is "enforce", "advisory" or "off"
+	StockCheck        string
+	Streams           Streams
+	LoaderConcurrency int
+	LowStock          LowStock
+	CacheTTL          time.Duration
+	// WebSocketOrigin accepts the origins of websocket upgrades, nil only
+	// accepts the gateway's own origin
+	WebSocketOrigin func(r *http.Request) bool
+	// Handler serves /graphql with these settings
+	Handler http.Handler
+}
+
+var current atomic.Pointer[Settings]
+
+func init() {
+	current.Store(&Settings{
+		SessionCookie: Cookie{
+			Name:     "jwtToken",
+			Path:     "/",
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		StockCheck: "enforce",
+		Streams: Streams{
+			MaxItems: 10000,
+			Timeout:  15 * time.Second,
+			Policy:   "fail",
+		},
+		LoaderConcurrency: 8,
+		LowStock:          LowStock{Threshold: 5, PerProduct: map[uint32]int32{}},
+		CacheTTL:          30 * time.Second,
+		Handler:           http.NotFoundHandler(),
+	})
+}
+
+// Current returns the snapshot published last. s must not be changed.
+func Current() *Settings {
+	return current.Load()
+}
+
+// Publish makes s the snapshot of every request from now on, requests in
+// flight keep the one they started with.
+func Publish(s *Settings) {
+	current.Store(s)
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, s *Settings) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// From returns the snapshot of the request ctx belongs to, or the current one
+// outside of a request.
+func From(ctx context.Context) *Settings {
+	if ctx != nil {
+		if s, ok := ctx.Value(contextKey{}).(*Settings); ok {
+			return s
+		}
+	}
+	return Current()
+}
@@ -0,0 +1,346 @@
+package subscription
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// Protocol is the graphql-transport-ws sub protocol.
+const Protocol = "graphql-transport-ws"
+
+const (
+	msgConnectionInit = "connection_init"
+	msgConnectionAck  = "connection_ack"
+	msgPing           = "ping"
+	msgPong           = "pong"
+	msgSubscribe      = "subscribe"
+	msgNext           = "next"
+	msgError          = "error"
+	msgComplete       = "complete"
+)
+
+const (
+	closeInvalidMessage  = 4400
+	closeUnauthorized    = 4401
+	closeInitTimeout     = 4408
+	closeSubscriberExist = 4409
+	closeTooManyInits    = 4429
+)
+
+type message struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type subscribePayload struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// ContextFn builds the context of a connection out of the upgrade request and
+// the connection_init payload.
+type ContextFn func(r *http.Request, initPayload map[string]interface{}) context.Context
+
+// ExpiresFn returns when the credentials of a connection expire, the zero
+// time when they do not.
+type ExpiresFn func(ctx context.Context) time.Time
+
+// Handler serves subscriptions over graphql-transport-ws. Queries and
+// mutations are rejected, they are served over HTTP only.
+type Handler struct {
+	Schema        *graphql.Schema
+	FormatErrorFn func(err error) gqlerrors.FormattedError
+	ContextFn     ContextFn
+	// ExpiresFn, when set, closes connections once their credentials
+	// expire
+	ExpiresFn ExpiresFn
+	// CheckOrigin accepts or refuses the upgrade request, nil only accepts
+	// the origin of the gateway itself
+	CheckOrigin func(r *http.Request) bool
+	InitTimeout time.Duration
+	upgrader    websocket.Upgrader
+}
+
+func NewHandler(schema *graphql.Schema, formatErrorFn func(err error) gqlerrors.FormattedError, contextFn ContextFn) *Handler {
+	h := &Handler{
+		Schema:        schema,
+		FormatErrorFn: formatErrorFn,
+		ContextFn:     contextFn,
+		InitTimeout:   10 * time.Second,
+	}
+	h.upgrader = websocket.Upgrader{
+		Subprotocols: []string{Protocol},
+		CheckOrigin: func(r *http.Request) bool {
+			if h.CheckOrigin != nil {
+				return h.CheckOrigin(r)
+			}
+			return SameOrigin(r)
+		},
+	}
+	return h
+}
+
+// SameOrigin reports whether the upgrade request r comes from the page of the
+// gateway itself or from a client that sends no Origin.
+func SameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// IsWebSocket reports whether r asks for a websocket upgrade.
+func IsWebSocket(r *http.Request) bool {
+	return websocket.IsWebSocketUpgrade(r)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("websocket upgrade:", err.Error())
+		return
+	}
+	if conn.Subprotocol() != Protocol {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseProtocolError, "unsupported sub protocol"))
+		conn.Close()
+		return
+	}
+
+	c := &connection{
+		handler: h,
+		conn:    conn,
+		request: r,
+		subs:    make(map[string]context.CancelFunc),
+	}
+	c.serve()
+}
+
+type connection struct {
+	handler *Handler
+	conn    *websocket.Conn
+	request *http.Request
+	ctx     context.Context
+
+	writeMu sync.Mutex
+	subsMu  sync.Mutex
+	subs    map[string]context.CancelFunc
+}
+
+func (c *connection) serve() {
+	ctx, cancel := context.WithCancel(c.request.Context())
+	defer func() {
+		cancel()
+		c.conn.Close()
+	}()
+
+	initialised := false
+	c.conn.SetReadDeadline(time.Now().Add(c.handler.InitTimeout))
+
+	for {
+		var msg message
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if !initialised {
+				c.close(closeInitTimeout, "Connection initialisation timeout")
+			}
+			return
+		}
+
+		switch msg.Type {
+		case msgConnectionInit:
+			if initialised {
+				c.close(closeTooManyInits, "Too many initialisation requests")
+				return
+			}
+			var payload map[string]interface{}
+			if len(msg.Payload) > 0 {
+				json.Unmarshal(msg.Payload, &payload)
+			}
+			c.ctx = ctx
+			if c.handler.ContextFn != nil {
+				c.ctx = c.handler.ContextFn(c.request.WithContext(ctx), payload)
+			}
+			if c.handler.ExpiresFn != nil {
+				if expiresAt := c.handler.ExpiresFn(c.ctx); !expiresAt.IsZero() {
+					// closing the connection ends the read loop and with
+					// it every subscription
+					timer := time.AfterFunc(time.Until(expiresAt), func() {
+						c.close(closeUnauthorized, "Token expired")
+						c.conn.Close()
+					})
+					defer timer.Stop()
+				}
+			}
+			initialised = true
+			c.conn.SetReadDeadline(time.Time{})
+			c.write(message{Type: msgConnectionAck})
+		case msgPing:
+			c.write(message{Type: msgPong})
+		case msgPong:
+		case msgSubscribe:
+			if !initialised {
+				c.close(closeUnauthorized, "Unauthorized")
+				return
+			}
+			var payload subscribePayload
+			if msg.ID == "" || json.Unmarshal(msg.Payload, &payload) != nil {
+				c.close(closeInvalidMessage, "Invalid subscribe message")
+				return
+			}
+			if !c.start(msg.ID, payload) {
+				c.close(closeSubscriberExist, "Subscriber for "+msg.ID+" already exists")
+				return
+			}
+		case msgComplete:
+			c.stop(msg.ID)
+		default:
+			c.close(closeInvalidMessage, "Invalid message type")
+			return
+		}
+	}
+}
+
+func (c *connection) start(id string, payload subscribePayload) bool {
+	c.subsMu.Lock()
+	if _, ok := c.subs[id]; ok {
+		c.subsMu.Unlock()
+		return false
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.subs[id] = cancel
+	c.subsMu.Unlock()
+
+	go func() {
+		defer c.stop(id)
+
+		params := graphql.Params{
+			Schema:         *c.handler.Schema,
+			RequestString:  payload.Query,
+			VariableValues: payload.Variables,
+			OperationName:  payload.OperationName,
+			Context:        ctx,
+		}
+
+		if op := operationType(payload.Query, payload.OperationName); op != "" && op != ast.OperationTypeSubscription {
+			c.reject(id, apperror.New(apperror.CodeBadUserInput, "only subscriptions are served over the websocket, send "+op+" operations over HTTP"))
+			return
+		}
+
+		first := true
+		for result := range graphql.Subscribe(params) {
+			if ctx.Err() != nil {
+				continue
+			}
+			if !c.send(id, result, first) {
+				cancel()
+			}
+			first = false
+		}
+		if ctx.Err() == nil {
+			c.write(message{ID: id, Type: msgComplete})
+		}
+	}()
+	return true
+}
+
+// send writes one result. A result without data that comes first is an
+// operation error and ends the subscription.
+func (c *connection) send(id string, result *graphql.Result, first bool) bool {
+	errs := c.formatErrors(result.Errors)
+	if first && result.Data == nil && len(errs) > 0 {
+		payload, _ := json.Marshal(errs)
+		c.write(message{ID: id, Type: msgError, Payload: payload})
+		return false
+	}
+	result.Errors = errs
+	payload, _ := json.Marshal(result)
+	c.write(message{ID: id, Type: msgNext, Payload: payload})
+	return true
+}
+
+// reject ends the operation id with err before it runs.
+func (c *connection) reject(id string, err error) {
+	formatted := gqlerrors.FormatError(err)
+	if c.handler.FormatErrorFn != nil {
+		formatted = c.handler.FormatErrorFn(err)
+	}
+	payload, _ := json.Marshal([]gqlerrors.FormattedError{formatted})
+	c.write(message{ID: id, Type: msgError, Payload: payload})
+}
+
+func (c *connection) formatErrors(errs []gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	if c.handler.FormatErrorFn == nil || len(errs) == 0 {
+		return errs
+	}
+	formatted := make([]gqlerrors.FormattedError, len(errs))
+	for i, err := range errs {
+		if original := err.OriginalError(); original != nil {
+			formatted[i] = c.handler.FormatErrorFn(original)
+		} else {
+			formatted[i] = err
+		}
+	}
+	return formatted
+}
+
+func (c *connection) stop(id string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	if cancel, ok := c.subs[id]; ok {
+		cancel()
+		delete(c.subs, id)
+	}
+}
+
+func (c *connection) write(msg message) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.WriteJSON(msg); err != nil {
+		log.Println("websocket write:", err.Error())
+	}
+}
+
+func (c *connection) close(code int, reason string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+}
+
+// operationType returns the type of the operation that will run. It is empty
+// for unparsable queries and unknown operations, which are left to
+// graphql.Subscribe to report.
+func operationType(query, operationName string) string {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return ""
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			return op.Operation
+		}
+	}
+	return ""
+}
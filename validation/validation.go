@@ -0,0 +1,132 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+)
+
+// Rule checks a single value and returns a message when it is invalid. Rules
+// other than Required skip missing values.
+type Rule func(value interface{}) string
+
+// Rules maps input field names to the rules applied to them.
+type Rules map[string][]Rule
+
+// Check applies rules to input and returns a BAD_USER_INPUT error listing
+// every invalid field, or nil.
+func Check(rules Rules, input map[string]interface{}) error {
+	fields := make([]string, 0, len(rules))
+	for field := range rules {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var fieldErrors []apperror.FieldError
+	for _, field := range fields {
+		for _, rule := range rules[field] {
+			if msg := rule(input[field]); msg != "" {
+				fieldErrors = append(fieldErrors, apperror.FieldError{Field: field, Message: msg})
+				break
+			}
+		}
+	}
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+	return &apperror.Error{
+		Code:    apperror.CodeBadUserInput,
+		Message: "invalid input",
+		Fields:  fieldErrors,
+	}
+}
+
+// Required rejects a missing value and a string of only whitespace.
+func Required() Rule {
+	return func(value interface{}) string {
+		if value == nil {
+			return "is required"
+		}
+		if s, ok := value.(string); ok && strings.TrimSpace(s) == "" {
+			return "is required"
+		}
+		return ""
+	}
+}
+
+// Length requires a string of min to max characters, counted in runes. A
+// max of 0 leaves the length unbounded.
+func Length(min, max int) Rule {
+	return func(value interface{}) string {
+		s, ok := value.(string)
+		if !ok {
+			return ""
+		}
+		n := len([]rune(s))
+		if n < min {
+			return fmt.Sprintf("must be at least %d characters", min)
+		}
+		if max > 0 && n > max {
+			return fmt.Sprintf("must be at most %d characters", max)
+		}
+		return ""
+	}
+}
+
+// Email requires a bare address, without a display name or angle brackets,
+// whose domain has a dot in it.
+func Email() Rule {
+	return func(value interface{}) string {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return ""
+		}
+		addr, err := mail.ParseAddress(s)
+		if err != nil || addr.Address != s || !strings.Contains(s[strings.LastIndex(s, "@"):], ".") {
+			return "must be a valid email address"
+		}
+		return ""
+	}
+}
+
+// Password requires at least one letter and one digit, length is checked
+// separately with Length.
+func Password() Rule {
+	return func(value interface{}) string {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return ""
+		}
+		var letter, digit bool
+		for _, r := range s {
+			switch {
+			case unicode.IsLetter(r):
+				letter = true
+			case unicode.IsDigit(r):
+				digit = true
+			}
+		}
+		if !letter || !digit {
+			return "must contain letters and digits"
+		}
+		return ""
+	}
+}
+
+// Range requires an int between min and max, both included.
+func Range(min, max int) Rule {
+	return func(value interface{}) string {
+		n, ok := value.(int)
+		if !ok {
+			return ""
+		}
+		if n < min || n > max {
+			return fmt.Sprintf("must be between %d and %d", min, max)
+		}
+		return ""
+	}
+}
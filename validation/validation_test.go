@@ -0,0 +1,103 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Nishad4140/api_gateway/apperror"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  Rule
+		value interface{}
+		want  string
+	}{
+		{"required missing", Required(), nil, "is required"},
+		{"required blank", Required(), "  ", "is required"},
+		{"required zero", Required(), 0, ""},
+		{"length short", Length(3, 5), "ab", "must be at least 3 characters"},
+		{"length long", Length(3, 5), "abcdef", "must be at most 5 characters"},
+		{"length runes", Length(3, 5), "héé", ""},
+		{"length unbounded", Length(1, 0), "a very long name indeed", ""},
+		{"length skips missing", Length(3, 5), nil, ""},
+		{"email", Email(), "ada@example.com", ""},
+		{"email skips empty", Email(), "", ""},
+		{"email without at", Email(), "ada.example.com", "must be a valid email address"},
+		{"email with display name", Email(), "Ada <ada@example.com>", "must be a valid email address"},
+		{"email in angle brackets", Email(), "<ada@example.com>", "must be a valid email address"},
+		{"email with surrounding space", Email(), " ada@example.com", "must be a valid email address"},
+		{"email without dot in domain", Email(), "ada@localhost", "must be a valid email address"},
+		{"email with dot only before at", Email(), "ada.lovelace@localhost", "must be a valid email address"},
+		{"password", Password(), "secret123", ""},
+		{"password without digit", Password(), "secret", "must contain letters and digits"},
+		{"password without letter", Password(), "123456", "must contain letters and digits"},
+		{"range", Range(1, 5), 5, ""},
+		{"range below", Range(1, 5), 0, "must be between 1 and 5"},
+		{"range above", Range(1, 5), 6, "must be between 1 and 5"},
+		{"range skips other types", Range(1, 5), "6", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule(tt.value); got != tt.want {
+				t.Errorf("rule(%#v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	rules := Rules{
+		"password": {Required(), Length(8, 0), Password()},
+		"email":    {Required(), Email()},
+		"name":     {Required(), Length(2, 20)},
+		"age":      {Range(18, 130)},
+	}
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		want  []apperror.FieldError
+	}{
+		{
+			name:  "valid",
+			input: map[string]interface{}{"name": "Ada", "email": "ada@example.com", "password": "secret123", "age": 36},
+		},
+		{
+			name:  "every field invalid, sorted by field",
+			input: map[string]interface{}{"name": "A", "email": "ada@localhost", "password": "short", "age": 12},
+			want: []apperror.FieldError{
+				{Field: "age", Message: "must be between 18 and 130"},
+				{Field: "email", Message: "must be a valid email address"},
+				{Field: "name", Message: "must be at least 2 characters"},
+				{Field: "password", Message: "must be at least 8 characters"},
+			},
+		},
+		{
+			name:  "first failing rule of a field only",
+			input: map[string]interface{}{"name": "Ada", "email": "ada@example.com"},
+			want: []apperror.FieldError{
+				{Field: "password", Message: "is required"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(rules, tt.input)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Check() = %v, want nil", err)
+				}
+				return
+			}
+			var appErr *apperror.Error
+			if !errors.As(err, &appErr) || appErr.Code != apperror.CodeBadUserInput {
+				t.Fatalf("Check() = %v, want a BAD_USER_INPUT error", err)
+			}
+			if !reflect.DeepEqual(appErr.Fields, tt.want) {
+				t.Errorf("fields = %+v, want %+v", appErr.Fields, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,434 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusPending   = "PENDING"
+	StatusDelivered = "DELIVERED"
+	StatusDead      = "DEAD"
+)
+
+const (
+	historyLimit = 500
+	maxBackoff   = time.Hour
+	// deliveryWorkers is how many deliveries are posted at the same time, a
+	// slow endpoint only holds up one of them.
+	deliveryWorkers = 4
+)
+
+type Endpoint struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (e *Endpoint) accepts(event string) bool {
+	for _, accepted := range e.Events {
+		if accepted == event {
+			return true
+		}
+	}
+	return false
+}
+
+type Delivery struct {
+	ID            string          `json:"id"`
+	EndpointID    string          `json:"endpointId"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"lastError,omitempty"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
+
+type state struct {
+	Endpoints  []*Endpoint `json:"endpoints"`
+	Deliveries []*Delivery `json:"deliveries"`
+}
+
+// Dispatcher keeps the registered endpoints and a queue of deliveries that
+// survives restarts by being written to a JSON file. Failed deliveries are
+// retried with exponential backoff and dead lettered after MaxAttempts.
+type Dispatcher struct {
+	mu          sync.Mutex
+	path        string
+	state       state
+	sender      *Sender
+	maxAttempts int
+	wake        chan struct{}
+	// inflight maps the deliveries handed to a worker and not finished yet to
+	// their endpoint
+	inflight map[string]string
+}
+
+// NewDispatcher loads the store at path. The store holds the signing secrets
+// of the endpoints, so it has to be an absolute path and is only readable by
+// the gateway's user.
+func NewDispatcher(path string, sender *Sender) (*Dispatcher, error) {
+	if path == "" {
+		return nil, errors.New("no webhook store path is configured")
+	}
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("webhook store path %s is not absolute", path)
+	}
+	d := &Dispatcher{
+		path:        path,
+		sender:      sender,
+		maxAttempts: sender.MaxAttempts,
+		wake:        make(chan struct{}, 1),
+		inflight:    map[string]string{},
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &d.state); err != nil {
+			return nil, fmt.Errorf("reading webhook store %s: %w", path, err)
+		}
+	}
+	return d, nil
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// save writes the state to a temporary file and renames it over the store so
+// a crash never leaves a half written file. The temporary file is created
+// with mode 0600. Must be called with mu held.
+func (d *Dispatcher) save() {
+	data, err := json.MarshalIndent(d.state, "", "  ")
+	if err != nil {
+		log.Println("webhook store:", err.Error())
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(d.path), ".webhooks-*")
+	if err != nil {
+		log.Println("webhook store:", err.Error())
+		return
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		log.Println("webhook store:", err.Error())
+		return
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), d.path); err != nil {
+		os.Remove(tmp.Name())
+		log.Println("webhook store:", err.Error())
+	}
+}
+
+func (d *Dispatcher) Register(rawURL, secret string, eventNames []string) (*Endpoint, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook url %q", rawURL)
+	}
+	if len(eventNames) == 0 {
+		return nil, fmt.Errorf("at least one event is required")
+	}
+	if secret == "" {
+		secret = newID() + newID()
+	}
+
+	endpoint := &Endpoint{
+		ID:        newID(),
+		URL:       rawURL,
+		Secret:    secret,
+		Events:    eventNames,
+		CreatedAt: time.Now(),
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.state.Endpoints = append(d.state.Endpoints, endpoint)
+	d.save()
+	return endpoint, nil
+}
+
+func (d *Dispatcher) Remove(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, endpoint := range d.state.Endpoints {
+		if endpoint.ID == id {
+			d.state.Endpoints = append(d.state.Endpoints[:i], d.state.Endpoints[i+1:]...)
+			d.save()
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dispatcher) Endpoints() []Endpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	endpoints := make([]Endpoint, 0, len(d.state.Endpoints))
+	for _, endpoint := range d.state.Endpoints {
+		endpoints = append(endpoints, *endpoint)
+	}
+	return endpoints
+}
+
+// Deliveries returns the newest deliveries first, optionally filtered by
+// endpoint and status.
+func (d *Dispatcher) Deliveries(endpointID, status string, limit int) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var deliveries []Delivery
+	for i := len(d.state.Deliveries) - 1; i >= 0 && (limit <= 0 || len(deliveries) < limit); i-- {
+		delivery := d.state.Deliveries[i]
+		if endpointID != "" && delivery.EndpointID != endpointID {
+			continue
+		}
+		if status != "" && delivery.Status != status {
+			continue
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries
+}
+
+// Retry puts a dead lettered delivery back on the queue.
+func (d *Dispatcher) Retry(id string) (*Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, delivery := range d.state.Deliveries {
+		if delivery.ID != id {
+			continue
+		}
+		if delivery.Status != StatusDead {
+			return nil, fmt.Errorf("only dead deliveries can be retried")
+		}
+		delivery.Status = StatusPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = time.Now()
+		delivery.UpdatedAt = time.Now()
+		d.save()
+		d.notify()
+		copied := *delivery
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("delivery %s not found", id)
+}
+
+// Enqueue queues a delivery of payload for every endpoint subscribed to event.
+func (d *Dispatcher) Enqueue(event string, payload interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	queued := false
+	for _, endpoint := range d.state.Endpoints {
+		if !endpoint.accepts(event) {
+			continue
+		}
+		id := newID()
+		body, err := json.Marshal(map[string]interface{}{
+			"id":         id,
+			"event":      event,
+			"occurredAt": now,
+			"data":       payload,
+		})
+		if err != nil {
+			log.Println("webhook payload:", err.Error())
+			return
+		}
+		d.state.Deliveries = append(d.state.Deliveries, &Delivery{
+			ID:            id,
+			EndpointID:    endpoint.ID,
+			Event:         event,
+			Payload:       body,
+			Status:        StatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+		queued = true
+	}
+	if queued {
+		d.trim()
+		d.save()
+		d.notify()
+	}
+}
+
+// trim drops the oldest finished deliveries beyond the history limit. Must be
+// called with mu held.
+func (d *Dispatcher) trim() {
+	finished := 0
+	for _, delivery := range d.state.Deliveries {
+		if delivery.Status != StatusPending {
+			finished++
+		}
+	}
+	if finished <= historyLimit {
+		return
+	}
+	drop := finished - historyLimit
+	kept := d.state.Deliveries[:0]
+	for _, delivery := range d.state.Deliveries {
+		if drop > 0 && delivery.Status != StatusPending {
+			drop--
+			continue
+		}
+		kept = append(kept, delivery)
+	}
+	d.state.Deliveries = kept
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers the queue until ctx is done. Events are queued by Enqueue,
+// which persists them before it returns.
+func (d *Dispatcher) Run(ctx context.Context) {
+	jobs := make(chan deliveryJob)
+	for i := 0; i < deliveryWorkers; i++ {
+		go d.work(ctx, jobs)
+	}
+	d.schedule(ctx, jobs)
+}
+
+type deliveryJob struct {
+	delivery Delivery
+	endpoint Endpoint
+}
+
+// schedule hands the due deliveries to the workers, oldest first.
+func (d *Dispatcher) schedule(ctx context.Context, jobs chan<- deliveryJob) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+		for _, job := range d.due() {
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- job:
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) work(ctx context.Context, jobs <-chan deliveryJob) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-jobs:
+			err := d.sender.Post(ctx, job.endpoint.URL, job.endpoint.Secret, job.delivery.Event, job.delivery.Payload)
+			d.finish(job.delivery.ID, err)
+		}
+	}
+}
+
+// due marks the pending deliveries whose attempt is due as in flight and
+// returns them, at most one per endpoint so a slow endpoint holds up a single
+// worker. Deliveries to removed endpoints are dead lettered.
+func (d *Dispatcher) due() []deliveryJob {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	endpoints := make(map[string]*Endpoint, len(d.state.Endpoints))
+	for _, endpoint := range d.state.Endpoints {
+		endpoints[endpoint.ID] = endpoint
+	}
+	busy := make(map[string]bool, len(d.inflight))
+	for _, endpointID := range d.inflight {
+		busy[endpointID] = true
+	}
+	now := time.Now()
+	changed := false
+	var jobs []deliveryJob
+	for _, delivery := range d.state.Deliveries {
+		if delivery.Status != StatusPending || delivery.NextAttemptAt.After(now) || busy[delivery.EndpointID] {
+			continue
+		}
+		endpoint, ok := endpoints[delivery.EndpointID]
+		if !ok {
+			delivery.Status = StatusDead
+			delivery.LastError = "endpoint was removed"
+			delivery.UpdatedAt = now
+			changed = true
+			continue
+		}
+		d.inflight[delivery.ID] = endpoint.ID
+		busy[endpoint.ID] = true
+		jobs = append(jobs, deliveryJob{delivery: *delivery, endpoint: *endpoint})
+	}
+	if changed {
+		d.save()
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].delivery.CreatedAt.Before(jobs[j].delivery.CreatedAt)
+	})
+	return jobs
+}
+
+// finish records the outcome of an attempt and saves the queue.
+func (d *Dispatcher) finish(id string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inflight, id)
+	defer func() {
+		d.trim()
+		d.save()
+		d.notify()
+	}()
+	for _, delivery := range d.state.Deliveries {
+		if delivery.ID != id {
+			continue
+		}
+		now := time.Now()
+		delivery.Attempts++
+		delivery.UpdatedAt = now
+		if err == nil {
+			delivery.Status = StatusDelivered
+			delivery.LastError = ""
+			return
+		}
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= d.maxAttempts {
+			delivery.Status = StatusDead
+			log.Printf("webhook delivery %s dead lettered after %d attempts: %v", id, delivery.Attempts, err)
+			return
+		}
+		backoff := d.sender.BaseDelay << (delivery.Attempts - 1)
+		if backoff <= 0 || backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		delivery.NextAttemptAt = now.Add(backoff)
+		return
+	}
+}
@@ -0,0 +1,188 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestDispatcher(t *testing.T, path string, maxAttempts int) *Dispatcher {
+	t.Helper()
+	d, err := NewDispatcher(path, NewSender(maxAttempts, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// waitFor polls the deliveries of d until one has status.
+func waitFor(t *testing.T, d *Dispatcher, status string) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if deliveries := d.Deliveries("", status, 1); len(deliveries) > 0 {
+			return deliveries[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no delivery reached %s: %+v", status, d.Deliveries("", "", 0))
+	return Delivery{}
+}
+
+func TestNewDispatcherRejectsRelativePath(t *testing.T) {
+	if _, err := NewDispatcher("webhooks.json", NewSender(1, time.Second)); err == nil {
+		t.Error("relative store path was accepted")
+	}
+	if _, err := NewDispatcher("", NewSender(1, time.Second)); err == nil {
+		t.Error("empty store path was accepted")
+	}
+}
+
+func TestEnqueuePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	d := newTestDispatcher(t, path, 3)
+	endpoint, err := d.Register("https://example.com/hook", "", []string{"ORDER_PLACED"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Enqueue("ORDER_PLACED", map[string]int{"orderId": 1})
+	d.Enqueue("ORDER_CANCELLED", map[string]int{"orderId": 1})
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("store mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	reloaded := newTestDispatcher(t, path, 3)
+	if endpoints := reloaded.Endpoints(); len(endpoints) != 1 || endpoints[0].Secret != endpoint.Secret {
+		t.Errorf("reloaded endpoints = %+v, want %+v", endpoints, endpoint)
+	}
+	deliveries := reloaded.Deliveries("", StatusPending, 0)
+	if len(deliveries) != 1 {
+		t.Fatalf("reloaded %d pending deliveries, want 1: %+v", len(deliveries), deliveries)
+	}
+	if deliveries[0].EndpointID != endpoint.ID || deliveries[0].Event != "ORDER_PLACED" {
+		t.Errorf("reloaded delivery = %+v", deliveries[0])
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header, body: body}
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(t, filepath.Join(t.TempDir(), "webhooks.json"), 3)
+	if _, err := d.Register(server.URL, "secret", []string{"ORDER_PLACED"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+	d.Enqueue("ORDER_PLACED", map[string]int{"orderId": 1})
+
+	delivered := waitFor(t, d, StatusDelivered)
+	if delivered.Attempts != 1 {
+		t.Errorf("attempts = %d, want 1", delivered.Attempts)
+	}
+	req := <-requests
+	if string(req.body) != string(delivered.Payload) {
+		t.Errorf("body = %s, want %s", req.body, delivered.Payload)
+	}
+	if req.header.Get(EventHeader) != "ORDER_PLACED" {
+		t.Errorf("event header = %q", req.header.Get(EventHeader))
+	}
+	timestamp, err := strconv.ParseInt(req.header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header: %v", err)
+	}
+	if got, want := req.header.Get(SignatureHeader), Sign("secret", timestamp, req.body); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(t, filepath.Join(t.TempDir(), "webhooks.json"), 2)
+	if _, err := d.Register(server.URL, "", []string{"ORDER_PLACED"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+	d.Enqueue("ORDER_PLACED", map[string]int{"orderId": 1})
+
+	dead := waitFor(t, d, StatusDead)
+	if dead.Attempts != 2 || calls.Load() != 2 {
+		t.Errorf("attempts = %d, calls = %d, want 2", dead.Attempts, calls.Load())
+	}
+	if dead.LastError == "" {
+		t.Error("dead delivery has no last error")
+	}
+
+	if _, err := d.Retry(dead.ID); err != nil {
+		t.Fatal(err)
+	}
+	if pending := d.Deliveries("", StatusPending, 0); len(pending) != 1 || pending[0].Attempts != 0 {
+		t.Errorf("retried delivery = %+v, want pending with no attempts", pending)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := newTestDispatcher(t, filepath.Join(t.TempDir(), "webhooks.json"), 10)
+	d.sender.BaseDelay = time.Minute
+	if _, err := d.Register("https://example.com/hook", "", []string{"ORDER_PLACED"}); err != nil {
+		t.Fatal(err)
+	}
+	d.Enqueue("ORDER_PLACED", nil)
+	id := d.Deliveries("", StatusPending, 1)[0].ID
+
+	for attempt, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 32 * time.Minute, maxBackoff, maxBackoff} {
+		start := time.Now()
+		d.finish(id, errors.New("unavailable"))
+		delivery := d.Deliveries("", StatusPending, 1)[0]
+		if got := delivery.NextAttemptAt.Sub(start); got < want || got > want+time.Second {
+			t.Errorf("attempt %d: next attempt in %v, want %v", attempt+1, got, want)
+		}
+	}
+}
+
+func TestRemovedEndpointIsDeadLettered(t *testing.T) {
+	d := newTestDispatcher(t, filepath.Join(t.TempDir(), "webhooks.json"), 3)
+	endpoint, err := d.Register("https://example.com/hook", "", []string{"ORDER_PLACED"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Enqueue("ORDER_PLACED", nil)
+	d.Remove(endpoint.ID)
+
+	if jobs := d.due(); len(jobs) != 0 {
+		t.Errorf("due = %+v, want no jobs", jobs)
+	}
+	if dead := d.Deliveries("", StatusDead, 0); len(dead) != 1 || dead[0].LastError != "endpoint was removed" {
+		t.Errorf("dead deliveries = %+v", dead)
+	}
+}
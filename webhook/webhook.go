@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Nishad4140/api_gateway/events"
+)
+
+// forwardQueueSize is how many events Forward holds while it delivers one.
+const forwardQueueSize = 64
+
+const (
+	SignatureHeader = "X-Gateway-Signature"
+	TimestampHeader = "X-Gateway-Timestamp"
+	EventHeader     = "X-Gateway-Event"
+)
+
+// Sign returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>", where
+// timestamp is the unix time sent in the timestamp header. Receivers
+// recompute it with the shared secret, compare it to the signature header
+// and reject old timestamps so that a captured delivery cannot be replayed.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender posts signed JSON payloads and retries failed deliveries with
+// exponential backoff.
+type Sender struct {
+	Client      *http.Client
+	MaxAttempts int
+	BaseDelay   time.Duration
+}
+
+func NewSender(maxAttempts int, baseDelay time.Duration) *Sender {
+	return &Sender{
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: maxAttempts,
+		BaseDelay:   baseDelay,
+	}
+}
+
+// Post delivers body once. Any non 2xx response is an error.
+func (s *Sender) Post(ctx context.Context, url, secret, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	if secret != "" {
+		// every attempt is signed afresh, retries are not replays
+		timestamp := time.Now().Unix()
+		req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+	}
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook %s responded with %s", url, res.Status)
+	}
+	return nil
+}
+
+// Send marshals payload and delivers it, retrying up to MaxAttempts times.
+func (s *Sender) Send(ctx context.Context, url, secret, event string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	delay := s.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err = s.Post(ctx, url, secret, event, body)
+		if err == nil || attempt >= s.MaxAttempts {
+			return err
+		}
+		log.Printf("webhook delivery of %s failed (attempt %d): %v", event, attempt, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// Forward delivers every event published on topic to url until ctx is done.
+// Events are delivered one at a time, the subscription buffer is the queue:
+// while a slow endpoint holds it up, the bus drops the events that do not
+// fit instead of piling up goroutines.
+func Forward(ctx context.Context, bus *events.Bus, topic, url, secret string, sender *Sender) {
+	ch, unsubscribe := bus.Subscribe(topic, forwardQueueSize)
+	defer unsubscribe()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := sender.Send(ctx, url, secret, topic, event); err != nil {
+				log.Printf("webhook delivery of %s to %s failed: %v", topic, url, err)
+			}
+		}
+	}
+}